@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,12 +15,20 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	ISO8601BasicFormat      = "20060102T150405Z"
 	ISO8601BasicFormatShort = "20060102"
+
+	// algorithm is the signing algorithm of signature version 4.
+	algorithm = "AWS4-HMAC-SHA256"
+
+	// unsignedPayload replaces the payload hash in the canonical request of a
+	// request whose body isn't signed.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
 )
 
 var (
@@ -102,11 +111,15 @@ func toHex(x []byte) []byte {
 
 // Get secret and access ID keys (in that order) from environment variables
 // AWS_SECRET_KEY and AWS_ACCESS_KEY.
+//
+// Deprecated: use EnvProvider.
 func KeysFromEnviroment() (string, string) {
 	return os.Getenv("AWS_SECRET_KEY"), os.Getenv("AWS_ACCESS_KEY")
 }
 
 // Get secret and access ID keys (in that order) from a file.
+//
+// Deprecated: use FileProvider.
 func KeysFromFile(name string) (string, string, error) {
 	file, err := os.Open(name)
 	if err != nil {
@@ -119,31 +132,102 @@ func KeysFromFile(name string) (string, string, error) {
 	return secret, access, err
 }
 
+// ExpiryWindow is how long before temporary credentials expire that a
+// Signature with a Provider asks it for new credentials.
+const ExpiryWindow = 5 * time.Minute
+
 // Signature contains the access ID key, UTC date in YYYYMMDD format, region,
-// service name, and the signing key.
+// service name, and the signing key. For temporary credentials it also
+// contains the session token and when the credentials expire.
+//
+// If NewKeys is set it is called for new keys when the date changes, otherwise
+// if Provider is set new credentials are requested from it when the date
+// changes or the credentials are about to expire.
+//
+// The time requests are signed for is taken from Clock, or the system clock if
+// it is nil, plus ClockOffset. If CorrectClockSkew is set AdjustClock sets
+// ClockOffset from the server's time when a request is rejected for being
+// signed too far from it.
+//
+// Options control how requests are canonicalized for services that differ from
+// the defaults, such as S3.
+//
+// A Signature is safe for concurrent use by multiple goroutines once created,
+// provided its fields are not modified directly, and must not be copied.
 type Signature struct {
-	AccessID   string
-	Date       string
-	Region     *Region
-	Service    string
-	SigningKey [sha256.Size]byte
-	NewKeys    func() (string, string) // function to get keys if date changes
+	AccessID         string
+	Date             string
+	Region           *Region
+	Service          string
+	SigningKey       [sha256.Size]byte
+	NewKeys          func() (string, string) // Deprecated: use Provider.
+	Provider         CredentialsProvider
+	SessionToken     string
+	Expiration       time.Time
+	Clock            Clock
+	ClockOffset      time.Duration
+	CorrectClockSkew bool
+	Options          SigningOptions
+
+	mu     sync.Mutex
+	keys   *signingKeys // shared with the signatures from ForScope
+	parent *Signature   // whose clock offset is used, if from ForScope
 }
 
 // NewSignature creates a new signature from the secret key, access key,
 // region, and service with the date set to UTC now.
 func NewSignature(secret, access string, r *Region, service string) *Signature {
+	return NewSessionSignature(secret, access, "", r, service)
+}
+
+// NewSessionSignature creates a new signature from temporary credentials, the
+// secret key, access key, and session token, for the region and service with
+// the date set to UTC now.
+func NewSessionSignature(secret, access, token string, r *Region, service string) *Signature {
 	var s Signature
 
-	s.AccessID = access
-	s.Date = time.Now().UTC().Format(ISO8601BasicFormatShort)
+	c := &Credentials{AccessID: access, Secret: secret, SessionToken: token}
 	s.Region = r
 	s.Service = service
-	s.generateSigningKey(secret)
+	s.Provider = (*StaticProvider)(c)
+	s.setCredentials(c, s.now().Format(ISO8601BasicFormatShort))
 
 	return &s
 }
 
+// NewSignatureFromProvider creates a new signature using the credentials from
+// the provider for the region and service with the date set to UTC now.
+//
+// Returns the signature or the provider's error.
+func NewSignatureFromProvider(p CredentialsProvider, r *Region, service string) (*Signature, error) {
+	c, err := p.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	var s Signature
+
+	s.Region = r
+	s.Service = service
+	s.Provider = p
+	s.setCredentials(c, s.now().Format(ISO8601BasicFormatShort))
+
+	return &s, nil
+}
+
+// setCredentials sets the signature's credentials and date and creates the
+// signing key for them.
+func (s *Signature) setCredentials(c *Credentials, date string) {
+	s.AccessID = c.AccessID
+	s.SessionToken = c.SessionToken
+	s.Expiration = c.Expiration
+	s.Date = date
+	if s.keys == nil {
+		s.keys = newSigningKeys()
+	}
+	s.SigningKey = s.keys.get(c.AccessID, c.Secret, date, s.Region.Name, s.Service)
+}
+
 // AWS signature Version 4 requires that you sign your message using a key that
 // is derived from your secret access key rather than using the secret access
 // key directly. See
@@ -153,57 +237,345 @@ func NewSignature(secret, access string, r *Region, service string) *Signature {
 //  * This is a separate function so that test suite can set a custom date.
 //
 func (s *Signature) generateSigningKey(secret string) {
+	s.SigningKey = deriveSigningKey(secret, s.Date, s.Region.Name, s.Service)
+}
+
+// deriveSigningKey returns the signing key of the secret for the date (in
+// YYYYMMDD format), region, and service.
+func deriveSigningKey(secret, date, region, service string) (key [sha256.Size]byte) {
 
 	// Get an HMAC digest of the date using a key that
 	// is our AWS secret prepended with the string "AWS4".
 	h := hmac.New(sha256.New, []byte("AWS4"+secret))
-	h.Write([]byte(s.Date))
+	h.Write([]byte(date))
 
 	// Get an HMAC digest of the region name using a key that
 	// is the HMAC digest computed in the previous step.
 	h = hmac.New(sha256.New, h.Sum(nil))
-	h.Write([]byte(s.Region.Name))
+	h.Write([]byte(region))
 
 	// Repeat for service name.
 	h = hmac.New(sha256.New, h.Sum(nil))
-	h.Write([]byte(s.Service))
+	h.Write([]byte(service))
 
 	// Repeat for the string "aws4_request".
 	h = hmac.New(sha256.New, h.Sum(nil))
 	h.Write([]byte("aws4_request"))
 
-	// Copy this HMAC into the s.SigningKey byte array.
-	h.Sum(s.SigningKey[:0])
+	// Copy this HMAC into the key byte array.
+	h.Sum(key[:0])
+	return key
+}
+
+// refresh creates a new signing key if the date has changed or the
+// credentials are about to expire and we still have access to the secret and
+// access keys. The caller must hold s.mu.
+func (s *Signature) refresh(now time.Time) error {
+	today := now.Format(ISO8601BasicFormatShort)
+	if s.Date == today && !expiring(s.Expiration, now, ExpiryWindow) {
+		return nil
+	}
+	switch {
+	case s.NewKeys != nil:
+		access, secret := s.NewKeys()
+		s.AccessID = access
+		s.Date = today
+		s.generateSigningKey(secret)
+	case s.Provider != nil:
+		c, err := s.Provider.Credentials()
+		if err != nil {
+			return err
+		}
+		s.setCredentials(c, today)
+	}
+	return nil
+}
+
+// credentialScope returns the date, region, service, and termination string
+// ("aws4_request") of the signature separated by slashes.
+func (s *Signature) credentialScope() string {
+	return s.Date + "/" + s.Region.Name + "/" + s.Service + "/aws4_request"
+}
+
+// signingState is a consistent copy of what requests are signed with, taken
+// so that the signature isn't locked while payloads are hashed.
+type signingState struct {
+	accessID     string
+	sessionToken string
+	date         string
+	credential   string
+	signingKey   [sha256.Size]byte
+	options      SigningOptions
+	now          time.Time
+}
+
+// state refreshes the signature if needed and returns a copy of what to sign
+// with at the current time.
+func (s *Signature) state() (*signingState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	err := s.refresh(now)
+	if err != nil {
+		return nil, err
+	}
+	return &signingState{
+		accessID:     s.AccessID,
+		sessionToken: s.SessionToken,
+		date:         s.Date,
+		credential:   s.credentialScope(),
+		signingKey:   s.SigningKey,
+		options:      s.Options,
+		now:          now,
+	}, nil
+}
+
+// signature returns the hex encoded HMAC of the string to sign using the
+// signing key.
+func signature(key [sha256.Size]byte, sts []byte) []byte {
+	h := hmac.New(sha256.New, key[:])
+	h.Write(sts)
+	return toHex(h.Sum(nil))
 }
 
-// Sign uses signature s to sign the HTTP request. It sets the Authorization
-// header and sets / overwrites the Date header for now.
-// If the signature was created on a different UTC day the signing will be
-// invalid.
+// Sign uses signature s to sign the HTTP request. It sets or replaces the
+// Authorization header, sets the X-Amz-Security-Token header if the signature
+// has a session token, and sets the X-Amz-Date header to now if the request
+// has neither an X-Amz-Date nor a Date header. The request is signed for the
+// time in its X-Amz-Date header, or its Date header, which must be on the
+// signature's UTC day.
 // The optional payload allows for various methods to hash the request's body.
-// If no payload is supplied the body is copyed into memory to be hashed.
+// If no payload is supplied the body is copyed into memory to be hashed, unless
+// the request has an X-Amz-Content-Sha256 header, whose value is signed
+// instead, such as UNSIGNED-PAYLOAD, which is set if the signature's options
+// say the payload is unsigned. A ChunkedPayload also sets the headers
+// describing the encoding of the body.
 //
-// Possible errors are an invalid URL query parameters (url.EscapeError), if
-// the date header isn't in time.RFC1123 format (*time.ParseError), or an error
-// when calculating the payload's hash.
+// Possible errors are an error from the signature's provider, invalid URL
+// query parameters (url.EscapeError), if the X-Amz-Date header isn't in
+// ISO8601BasicFormat or the Date header isn't in time.RFC1123 format
+// (*time.ParseError), ErrDateMismatch, or an error when calculating the
+// payload's hash.
 func (s *Signature) Sign(r *http.Request, payload Payload) error {
-	// TODO check if header already has hash instead of parameter
+	return s.sign(r, payload, nil)
+}
+
+// sign signs the request, recording what was signed in debug if it isn't nil.
+func (s *Signature) sign(r *http.Request, payload Payload, debug *SigningDebug) error {
 	// TODO check all error cases first
 
-	// If the date has changed and we sill have access to the secret and access
-	// keys create a new signing key.
+	state, err := s.state()
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+
+	// Temporary credentials require the session token, which is signed like
+	// any other header.
 	//
-	if today := time.Now().UTC().Format(ISO8601BasicFormatShort); s.NewKeys != nil && s.Date != today {
-		access, secret := s.NewKeys()
-		s.AccessID = access
-		s.Date = today
-		s.generateSigningKey(secret)
+	if state.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", state.sessionToken)
+	}
+
+	// A chunked payload's headers describe how its body is encoded and have to
+	// be signed.
+	//
+	chunked, _ := payload.(*chunkedPayload)
+	if chunked != nil {
+		chunked.setHeaders(r)
+	} else if state.options.UnsignedPayload {
+		r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+
+	// The RequestDate value, which is specified by using the ISO8601 Basic
+	// format via the x-amz-date header in the YYYYMMDD'T'HHMMSS'Z' format. It
+	// is added if the request doesn't have a date so that it is signed.
+	//
+	dateTime, ok, err := headerDate(r)
+	if err != nil {
+		return err
 	}
+	if !ok {
+		dateTime = state.now
+		r.Header.Set("X-Amz-Date", dateTime.Format(ISO8601BasicFormat))
+	}
+
+	// The signing key is only valid for requests made on its day, AWS would
+	// reject the request.
+	//
+	if dateTime.Format(ISO8601BasicFormatShort) != state.date {
+		return ErrDateMismatch
+	}
+
+	headers := signedHeaders(r)
+
+	// Add the payload, which you derive from the body of the HTTP or HTTPS
+	// request. A chunked payload is hashed chunk by chunk as it is sent so the
+	// request only declares that it is streamed.
+	//
+	hexHash, err := payloadHash(r, payload)
+	if err != nil {
+		return err
+	}
+
+	cr := canonicalRequest(r, query, headers, hexHash, state.options)
+	credential := state.credential
+	sts := stringToSign(algorithm, dateTime, credential, cr)
+	sig := signature(state.signingKey, sts)
+	if chunked != nil {
+		chunked.seed(state.signingKey, dateTime, credential, sig)
+	}
+
+	// Add authorization parameters that AWS uses to ensure the validity and
+	// authenticity of the request.
+	//
+	var authz bytes.Buffer
+
+	// Algorithm: The method used to sign the request. For signature version 4,
+	// use the value AWS4-HMAC-SHA256.
+	//
+	authz.WriteString(algorithm)
+
+	// Credential: A slash('/')-separated string that is formed by concatenating
+	// your Access Key ID and your credential scope components. Credential scope
+	// comprises the date (YYYYMMDD), the AWS region, the service name, and a
+	// special termination string (aws4_request).
+	//
+	authz.WriteString(" Credential=")
+	authz.WriteString(state.accessID)
+	authz.WriteByte('/')
+	authz.WriteString(credential)
 
-	// Create the canonical request, which is the string we must sign
-	// with our derived key. See
-	// http://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
+	// SignedHeaders: A semicolon(';')-delimited list of HTTP headers to include
+	// in the signature.
+	//
+	authz.WriteString(", SignedHeaders=")
+	authz.WriteString(strings.Join(headers, ";"))
+
+	// Signature: A hexadecimal-encoded string from your derived signing key and
+	// your string to sign as inputs to the keyed hash function that you use to
+	// calculate the signature.
 	//
+	authz.WriteString(", Signature=")
+	authz.Write(sig)
+
+	r.Header.Set("Authorization", authz.String())
+
+	if debug != nil {
+		debug.CanonicalRequest = string(cr)
+		debug.StringToSign = string(sts)
+		debug.SignedHeaders = headers
+		debug.Authorization = authz.String()
+	}
+	return nil
+}
+
+// payloadHash returns the hex encoded payload hash the request is signed
+// with. It is the value of the request's X-Amz-Content-Sha256 header if it has
+// one, which may declare that the payload is unsigned or streamed, otherwise
+// the hash of the payload. The request's body is set as by hashPayload, but
+// isn't read if the header is set and payload is nil.
+func payloadHash(r *http.Request, payload Payload) ([]byte, error) {
+	declared := canonicalHeaderValue(r.Header.Get("X-Amz-Content-Sha256"))
+	if declared != "" && payload == nil {
+		return []byte(declared), nil
+	}
+	hash, err := hashPayload(r, payload)
+	if err != nil {
+		return nil, err
+	}
+	if declared != "" {
+		return []byte(declared), nil
+	}
+	return toHex(hash), nil
+}
+
+// hashPayload returns the hash of the payload and sets the request's body to
+// the payload's body if it has one. If payload is nil the request's body is
+// read into memory.
+func hashPayload(r *http.Request, payload Payload) ([]byte, error) {
+	if payload == nil {
+		var mem []byte
+		if r.Body != nil {
+			var err error
+			mem, err = ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			err = r.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		payload = MemoryPayload(mem)
+	}
+	body, hash, err := payload.Payload()
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Body = body
+	}
+	return hash, nil
+}
+
+// ErrDateMismatch is returned by Sign if the request's date is not on the
+// UTC day of the signature's signing key.
+var ErrDateMismatch = errors.New("aws: request date is not the signing key's date")
+
+// requestDate returns the time of the request from its X-Amz-Date or Date
+// header, or now if it has neither.
+func requestDate(r *http.Request, now time.Time) (time.Time, error) {
+	dateTime, ok, err := headerDate(r)
+	if !ok {
+		return now, err
+	}
+	return dateTime, err
+}
+
+// headerDate returns the UTC time of the request from its X-Amz-Date header
+// in ISO8601BasicFormat, or its Date header in time.RFC1123 format, and
+// whether it has either.
+func headerDate(r *http.Request) (time.Time, bool, error) {
+	if date := r.Header.Get("X-Amz-Date"); date != "" {
+		dateTime, err := time.Parse(ISO8601BasicFormat, date)
+		return dateTime, true, err
+	}
+	if date := r.Header.Get("Date"); date != "" {
+		dateTime, err := time.Parse(time.RFC1123, date)
+		return dateTime.UTC(), true, err
+	}
+	return time.Time{}, false, nil
+}
+
+// signedHeaders returns the sorted, lower case names of the request's headers
+// that are to be signed, which is all of them, other than a previous
+// Authorization header, and the host.
+func signedHeaders(r *http.Request) []string {
+	headers := make([]string, 0, len(r.Header)+1)
+	for i := range r.Header {
+		if http.CanonicalHeaderKey(i) == "Authorization" {
+			continue
+		}
+		headers = append(headers, strings.ToLower(i))
+	}
+	headers = append(headers, "host")
+	sort.Strings(headers)
+	return headers
+}
+
+// canonicalRequest creates the canonical request, which is the string we must
+// sign with our derived key. The query is the request's parsed query string,
+// headers are the sorted lower case names of the signed headers, and hash is
+// the hex encoded hash of the payload. The path is canonicalized according to
+// the options. See
+// http://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
+func canonicalRequest(r *http.Request, query url.Values, headers []string, hash []byte, options SigningOptions) []byte {
 	var crb bytes.Buffer // canonical request buffer
 
 	// 1 - Start with the HTTP request method (GET, PUT, POST, etc.).
@@ -216,77 +588,37 @@ func (s *Signature) Sign(r *http.Request, payload Payload) error {
 	// header to the question mark character ('?') that begins the query string
 	// parameters.
 	//
-	var cp bytes.Buffer // canonical path
-	parts := strings.Split(path.Clean(r.URL.Path)[1:], "/")
-	for i := range parts {
-		cp.WriteByte('/')
-		cp.WriteString(uriEncodeString(parts[i]))
-	}
-	crb.Write(cp.Bytes())
+	crb.WriteString(canonicalPath(r.URL.Path, options))
 	crb.WriteByte('\n')
 
 	// 3 - Add the CanonicalQueryString parameter. If the request does not
 	// include a query string, set the value of CanonicalQueryString to an empty
 	// string.
 	//
-	query, err := url.ParseQuery(r.URL.RawQuery)
-	if err != nil {
-		return err
-	}
-	keys := make([]string, 0, len(query))
-	for i := range query {
-		keys = append(keys, i)
-	}
-	sort.Strings(keys)
-	var cqs bytes.Buffer // canonical query string
-	for i := range keys {
-		if i > 0 {
-			cqs.WriteByte('&')
-		}
-		parameters := query[keys[i]]
-		sort.Strings(parameters)
-		for j := range parameters {
-			if j > 0 {
-				cqs.WriteByte('&')
-			}
-			cqs.WriteString(uriEncodeString(keys[i]))
-			cqs.WriteByte('=')
-			cqs.WriteString(uriEncodeString(parameters[j]))
-		}
-	}
-	if cqs.Len() > 0 {
-		crb.Write(cqs.Bytes())
-	}
+	crb.WriteString(canonicalQueryString(query))
 	crb.WriteByte('\n')
 
 	// 4 - Add the CanonicalHeaders parameter, which is a list of all the HTTP
 	// headers for the request. You must include a valid host header. Any other
 	// required headers are described by the service you're using.
 	//
-	// TODO:
-	//   * check for date and add if required
-	//
-	headers := make([]string, 0, len(r.Header)+1)
 	headersMap := make(map[string]string)
 	for i := range r.Header {
-		header := strings.ToLower(i)
-		headers = append(headers, header)
-		headersMap[header] = i
+		headersMap[strings.ToLower(i)] = i
 	}
-	headers = append(headers, "host")
-	sort.Strings(headers)
 	for i := range headers {
 		crb.WriteString(headers[i])
 		crb.WriteByte(':')
-		var value string
 		if headers[i] == "host" {
-			value = r.Host
+			crb.WriteString(canonicalHeaderValue(r.Host))
 		} else {
-			values := r.Header[headersMap[headers[i]]]
-			sort.Strings(values)
-			value = strings.Join(values, ",")
+			for j, value := range r.Header[headersMap[headers[i]]] {
+				if j > 0 {
+					crb.WriteByte(',')
+				}
+				crb.WriteString(canonicalHeaderValue(value))
+			}
 		}
-		crb.WriteString(value)
 		crb.WriteByte('\n')
 	}
 	crb.WriteByte('\n')
@@ -300,65 +632,111 @@ func (s *Signature) Sign(r *http.Request, payload Payload) error {
 	crb.WriteString(strings.Join(headers, ";"))
 	crb.WriteByte('\n')
 
-	// 6 - Add the payload, which you derive from the body of the HTTP
-	// or HTTPS request.
+	// 6 - Add the payload hash.
 	//
-	if payload == nil {
-		var mem []byte
-		if r.Body != nil {
-			mem, err = ioutil.ReadAll(r.Body)
-			if err != nil {
-				return err
-			}
-			err = r.Body.Close()
-			if err != nil {
-				return err
-			}
+	crb.Write(hash)
+
+	return crb.Bytes()
+}
+
+// canonicalPath returns the URI encoded path with redundant slashes and dot
+// segments removed, unless options disable path normalization. A trailing
+// slash is kept.
+func canonicalPath(p string, options SigningOptions) string {
+	if !options.DisablePathNormalization {
+		clean := path.Clean("/" + p)
+		if strings.HasSuffix(p, "/") && clean != "/" {
+			clean += "/"
 		}
-		payload = MemoryPayload(mem)
+		p = clean
 	}
-	body, hash, err := payload.Payload()
-	if err != nil {
-		return err
+	p = strings.TrimPrefix(p, "/")
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = uriEncodeString(parts[i])
+		if options.DoubleURIEncode {
+			parts[i] = uriEncodeString(parts[i])
+		}
 	}
-	if body != nil {
-		r.Body = body
+	return "/" + strings.Join(parts, "/")
+}
+
+// canonicalHeaderValue returns the header value with leading and trailing
+// whitespace removed and runs of whitespace, including the line breaks of
+// folded headers, replaced by a single space. Values of a header sent several
+// times are joined in the order they were sent.
+func canonicalHeaderValue(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
+
+// parseQuery parses the query string like url.ParseQuery, but semicolons are
+// part of keys and values rather than rejected, as AWS treats them.
+func parseQuery(raw string) (url.Values, error) {
+	query := make(url.Values)
+	for _, parameter := range strings.Split(raw, "&") {
+		if parameter == "" {
+			continue
+		}
+		key, value := parameter, ""
+		if i := strings.IndexByte(parameter, '='); i != -1 {
+			key, value = parameter[:i], parameter[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		query[key] = append(query[key], value)
 	}
-	crb.Write(toHex(hash))
+	return query, nil
+}
 
-	// Create the string to sign, which includes meta information about our
-	// request and the canonical request that we just created. See
-	// http://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
-	//
+// canonicalQueryString returns the query parameters sorted by key then value
+// with each key and value URI encoded.
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for i := range query {
+		keys = append(keys, i)
+	}
+	sort.Strings(keys)
+	var cqs bytes.Buffer // canonical query string
+	for i := range keys {
+		if i > 0 {
+			cqs.WriteByte('&')
+		}
+		parameters := query[keys[i]]
+		sort.Strings(parameters)
+		for j := range parameters {
+			if j > 0 {
+				cqs.WriteByte('&')
+			}
+			cqs.WriteString(uriEncodeString(keys[i]))
+			cqs.WriteByte('=')
+			cqs.WriteString(uriEncodeString(parameters[j]))
+		}
+	}
+	return cqs.String()
+}
+
+// stringToSign creates the string to sign, which includes meta information
+// about our request and the canonical request. See
+// http://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
+func stringToSign(algorithm string, dateTime time.Time, credential string, cr []byte) []byte {
 	var sts bytes.Buffer // string to sign
 
 	// 1 - Start with the Algorithm designation, followed by a newline
 	// character.
 	//
-	sts.WriteString("AWS4-HMAC-SHA256\n")
+	sts.WriteString(algorithm)
+	sts.WriteByte('\n')
 
 	// 2 - Append the RequestDate value, which is specified by using the ISO8601
 	// Basic format via the x-amz-date header in the YYYYMMDD'T'HHMMSS'Z'
 	// format. This value must match the value you used in any previous steps.
 	//
-	// Note:
-	//  * Parsing data just for test suite.
-	//
-	// TODO:
-	//  * Could be different day than signature. We should probably return an
-	//    error here instead of incurring the cost of sending the request to aws
-	//    and have it return an error.
-	var dateTime time.Time
-	dates, ok := r.Header["Date"]
-	if !ok || len(dates) < 1 {
-		dateTime = time.Now().UTC()
-		r.Header.Set("Date", dateTime.Format(time.RFC3339))
-	} else {
-		dateTime, err = time.Parse(time.RFC1123, dates[0])
-		if err != nil {
-			return err
-		}
-	}
 	sts.WriteString(dateTime.Format(ISO8601BasicFormat))
 	sts.WriteByte('\n')
 
@@ -367,60 +745,16 @@ func (s *Signature) Sign(r *http.Request, payload Payload) error {
 	// termination string ("aws4_request") in lowercase characters. The region
 	// and service name strings must be UTF-8 encoded.
 	//
-	credential := s.Date + "/" + s.Region.Name + "/" + s.Service + "/aws4_request"
 	sts.WriteString(credential)
 	sts.WriteByte('\n')
 
 	// 4 - Append the hashed canonical request. The hashed canonical request
 	// must be lowercase base-16 encoded, as defined by Section 8 of RFC 4648.
 	//
-	hasher := sha256.New()
-	var hashed [sha256.Size]byte
-	hasher.Write(crb.Bytes())
-	sts.Write(toHex(hasher.Sum(hashed[:0])))
-
-	// Add authorization parameters that AWS uses to ensure the validity and
-	// authenticity of the request.
-	//
-	var authz bytes.Buffer
-
-	// Algorithm: The method used to sign the request. For signature version 4,
-	// use the value AWS4-HMAC-SHA256.
-	//
-	authz.WriteString("AWS4-HMAC-SHA256")
+	hashed := sha256.Sum256(cr)
+	sts.Write(toHex(hashed[:]))
 
-	// Credential: A slash('/')-separated string that is formed by concatenating
-	// your Access Key ID and your credential scope components. Credential scope
-	// comprises the date (YYYYMMDD), the AWS region, the service name, and a
-	// special termination string (aws4_request).
-	//
-	authz.WriteString(" Credential=")
-	authz.WriteString(s.AccessID)
-	authz.WriteByte('/')
-	authz.WriteString(credential)
-
-	// SignedHeaders: A semicolon(';')-delimited list of HTTP headers to include
-	// in the signature.
-	//
-	authz.WriteString(", SignedHeaders=")
-	for i := range headers {
-		if i > 0 {
-			authz.WriteByte(';')
-		}
-		authz.WriteString(headers[i])
-	}
-
-	// Signature: A hexadecimal-encoded string from your derived signing key and
-	// your string to sign as inputs to the keyed hash function that you use to
-	// calculate the signature.
-	//
-	authz.WriteString(", Signature=")
-	h := hmac.New(sha256.New, s.SigningKey[:])
-	h.Write(sts.Bytes())
-	authz.Write(toHex(h.Sum(hashed[:0])))
-
-	r.Header.Add("Authorization", authz.String())
-	return nil
+	return sts.Bytes()
 }
 
 // Provides control over how the payload hash is calculated. If a payload is
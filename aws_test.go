@@ -3,7 +3,9 @@ package aws
 import (
 	"bufio"
 	"bytes"
+	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,6 +14,8 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -27,6 +31,16 @@ type awsTestCase struct {
 	body    io.ReadSeeker
 }
 
+// The 2011 suite's canonical requests sort the values of a header sent several
+// times, so p:z, p:a, p:p, p:a is signed as "p:a,a,p,z". AWS now signs them in
+// the order they were sent, as the 2015 suite's cases of the same names expect
+// and Sign does, so these 2011 cases are checked by the 2015 ones instead.
+//
+var supersededSuiteCases = map[string]bool{
+	"get-header-key-duplicate": true,
+	"get-header-value-order":   true,
+}
+
 // Get a list of the files in the AWS test suite.
 //
 func getAWSSuiteFiles(dir string) (files []string, err error) {
@@ -45,7 +59,8 @@ func getAWSSuiteFiles(dir string) (files []string, err error) {
 	files = make([]string, 0)
 	for i := 0; i < len(f)-1; {
 		if filepath.Ext(f[i]) == ".req" &&
-			filepath.Ext(f[i+1]) == ".sreq" {
+			filepath.Ext(f[i+1]) == ".sreq" &&
+			!supersededSuiteCases[f[i][:len(f[i])-4]] {
 			files = append(files, f[i][:len(f[i])-4])
 			i += 2
 		} else {
@@ -56,6 +71,90 @@ func getAWSSuiteFiles(dir string) (files []string, err error) {
 
 }
 
+// Parse a raw request from the test suites. Go's parser rejects several of
+// them, so the request is parsed as the suites intend: the target is escaped
+// where it isn't a valid URI, and header values are kept as they are, including
+// the line breaks of folded headers. The 2011 suite ends the target at the first
+// space, if spaces is set the target ends at the space before the version.
+//
+func readSuiteRequest(raw []byte, spaces bool) (*http.Request, io.ReadSeeker, error) {
+	head, body := raw, []byte(nil)
+	if i := bytes.Index(raw, []byte("\n\n")); i != -1 {
+		head, body = raw[:i], raw[i+2:]
+	}
+	lines := strings.Split(string(head), "\n")
+
+	line := lines[0]
+	i, j := strings.IndexByte(line, ' '), strings.LastIndexByte(line, ' ')
+	if i == -1 || i == j {
+		return nil, nil, fmt.Errorf("malformed request line %q", line)
+	}
+	target := line[i+1 : j]
+	if !spaces {
+		target = strings.Fields(target)[0]
+	}
+	u, err := url.ParseRequestURI(escapeSuiteTarget(target))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bytes.NewReader(body)
+	request := &http.Request{
+		Method: line[:i],
+		URL:    u,
+		Header: make(http.Header),
+		Body:   ioutil.NopCloser(reader),
+	}
+	var last string
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			values := request.Header[last]
+			if len(values) == 0 {
+				return nil, nil, fmt.Errorf("malformed header line %q", line)
+			}
+			values[len(values)-1] += "\n" + line
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i == -1 {
+			return nil, nil, fmt.Errorf("malformed header line %q", line)
+		}
+		last = http.CanonicalHeaderKey(line[:i])
+		if last == "Host" {
+			request.Host = line[i+1:]
+			continue
+		}
+		request.Header[last] = append(request.Header[last], line[i+1:])
+	}
+	return request, reader, nil
+}
+
+// Percent encode the characters of a request target that may not appear in a
+// URI, and percent signs that don't start an escape.
+//
+func escapeSuiteTarget(target string) string {
+	const hex = "0123456789ABCDEF"
+	isHex := func(c byte) bool {
+		return strings.IndexByte(hex, c) != -1 || strings.IndexByte("abcdef", c) != -1
+	}
+	var escaped bytes.Buffer
+	for i := 0; i < len(target); i++ {
+		c := target[i]
+		if c == '%' && i+2 < len(target) && isHex(target[i+1]) && isHex(target[i+2]) ||
+			c != '%' && c > ' ' && c < 0x7f && strings.IndexByte("\"#<>\\^`{|}[]", c) == -1 {
+			escaped.WriteByte(c)
+			continue
+		}
+		escaped.WriteByte('%')
+		escaped.WriteByte(hex[c>>4])
+		escaped.WriteByte(hex[c&0xf])
+	}
+	return escaped.String()
+}
+
 // Build a slice of awsTestCase structs based on the "gold standards"
 // distributed by Amazon and located in the aws4_testsuite directory.
 //
@@ -78,27 +177,10 @@ func buildAWSSuite() (tests []*awsTestCase, err error) {
 		if err != nil {
 			return
 		}
-		// Go doesn't like post requests with spaces in them
-		if d.base == "post-vanilla-query-nonunreserved" ||
-			d.base == "post-vanilla-query-space" ||
-			d.base == "get-slashes" {
-			// skip tests with spacing in URLs or invalid escapes or
-			// trailing slashes
-			continue
-		} else {
-
-			// Go doesn't like lowercase http
-			fixed := bytes.Replace(d.req, []byte("http"), []byte("HTTP"), 1)
-			reader := bufio.NewReader(bytes.NewBuffer(fixed))
-			d.request, err = http.ReadRequest(reader)
-			if err != nil {
-				return
-			}
-			delete(d.request.Header, "User-Agent")
-			if i := bytes.Index(d.req, []byte("\n\n")); i != -1 {
-				d.body = bytes.NewReader(d.req[i+2:])
-				d.request.Body = ioutil.NopCloser(d.body)
-			}
+		d.request, d.body, err = readSuiteRequest(d.req, false)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", f, err)
+			return
 		}
 
 		d.sreq, err = ioutil.ReadFile(dir + "/" + f + ".sreq")
@@ -117,12 +199,10 @@ func TestSignature(t *testing.T) {
 	secret := "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
 	access := "AKIDEXAMPLE"
 	signature := &Signature{
-		access,
-		date.Format(ISO8601BasicFormatShort),
-		USEast1,
-		"host",
-		[sha256.Size]byte{},
-		nil,
+		AccessID: access,
+		Date:     date.Format(ISO8601BasicFormatShort),
+		Region:   USEast1,
+		Service:  "host",
 	}
 	signature.generateSigningKey(secret)
 
@@ -138,9 +218,9 @@ func TestSignature(t *testing.T) {
 	// to match the signature in awsTestCase.
 	//
 	for _, f := range tests {
-		err := signature.Sign(f.request, nil)
+		debug, err := signature.SignWithDebug(f.request, nil)
 		if err != nil {
-			t.Error(err)
+			t.Error(f.base, err)
 			continue
 		}
 
@@ -168,6 +248,151 @@ func TestSignature(t *testing.T) {
 			t.Error(f.base, "signed request")
 			t.Logf("got:\n%s", sreq)
 			t.Logf("want:\n%s", f.sreq)
+			t.Logf("canonical request:\n%s", debug.CanonicalRequest)
+			t.Logf("string to sign:\n%s", debug.StringToSign)
+		}
+	}
+}
+
+// The 2015 test suite, which also has the expected canonical request and
+// string to sign of each case. Each case is a directory named after it, some
+// are grouped in directories, with the case's request (.req), canonical request
+// (.creq), string to sign (.sts), and Authorization header (.authz).
+//
+// Sessions are signed with the token of the request's X-Amz-Security-Token
+// header. The suite's post-sts-header-after case isn't included: it adds the
+// token after signing, which leaves it unsigned and is only accepted by some
+// services, while Sign always signs it as in post-sts-header-before, which all
+// services accept. Its expected values are otherwise those of post-vanilla.
+//
+func TestSignature2015(t *testing.T) {
+	dir := "aws4_testsuite_2015"
+	var cases []string
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(name) == ".req" {
+			cases = append(cases, strings.TrimSuffix(name, ".req"))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no test cases in", dir)
+	}
+
+	clock := &testClock{now: time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)}
+	for _, base := range cases {
+		read := func(ext string) string {
+			b, err := ioutil.ReadFile(base + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(b)
+		}
+		name, _ := filepath.Rel(dir, filepath.Dir(base))
+
+		request, _, err := readSuiteRequest([]byte(read(".req")), true)
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+		signature, err := NewSignatureFromProvider(&StaticProvider{
+			AccessID:     "AKIDEXAMPLE",
+			Secret:       "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			SessionToken: request.Header.Get("X-Amz-Security-Token"),
+		}, USEast1, "service")
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature.Clock = clock
+		signature.refresh(clock.Now())
+
+		debug, err := signature.SignWithDebug(request, nil)
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+
+		var diff bytes.Buffer
+		diffLines(&diff, "canonical request", debug.CanonicalRequest, read(".creq"))
+		diffLines(&diff, "string to sign", debug.StringToSign, read(".sts"))
+		diffLines(&diff, "authorization", debug.Authorization, read(".authz"))
+		if diff.Len() > 0 {
+			t.Errorf("%s:\n%s", name, diff.String())
+		}
+	}
+}
+
+// An unsigned payload isn't in the published suite, so the expected values are
+// derived from the spec: the canonical request ends with UNSIGNED-PAYLOAD in
+// place of the payload's hash, the string to sign has the canonical request's
+// hash, and the signature is the HMAC of the string to sign.
+func TestSignUnsignedPayload(t *testing.T) {
+	hmacSHA256 := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := []byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	for _, data := range []string{"20150830", "us-east-1", "service", "aws4_request"} {
+		key = hmacSHA256(key, data)
+	}
+
+	tests := []struct {
+		method, contentType, body string
+		canonical                 string
+	}{
+		{
+			"GET", "", "",
+			"GET\n/\n\n" +
+				"host:example.amazonaws.com\n" +
+				"x-amz-content-sha256:UNSIGNED-PAYLOAD\n" +
+				"x-amz-date:20150830T123600Z\n\n" +
+				"host;x-amz-content-sha256;x-amz-date\n" +
+				"UNSIGNED-PAYLOAD",
+		},
+		{
+			"POST", "application/x-www-form-urlencoded", "Param1=value1",
+			"POST\n/\n\n" +
+				"content-type:application/x-www-form-urlencoded\n" +
+				"host:example.amazonaws.com\n" +
+				"x-amz-content-sha256:UNSIGNED-PAYLOAD\n" +
+				"x-amz-date:20150830T123600Z\n\n" +
+				"content-type;host;x-amz-content-sha256;x-amz-date\n" +
+				"UNSIGNED-PAYLOAD",
+		},
+	}
+	for _, test := range tests {
+		signature := &Signature{AccessID: "AKIDEXAMPLE", Date: "20150830", Region: USEast1, Service: "service"}
+		signature.generateSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+		request, err := http.NewRequest(test.method, "http://example.amazonaws.com/", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.contentType != "" {
+			request.Header.Set("Content-Type", test.contentType)
+		}
+		request.Header.Set("X-Amz-Date", "20150830T123600Z")
+		request.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		debug, err := signature.SignWithDebug(request, nil)
+		if err != nil {
+			t.Fatal(test.method, err)
+		}
+
+		hash := sha256.Sum256([]byte(test.canonical))
+		sts := "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\n" +
+			hex.EncodeToString(hash[:])
+		signedHeaders := test.canonical[strings.LastIndex(test.canonical, "\n\n")+2 : strings.LastIndex(test.canonical, "\n")]
+		authz := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=" + signedHeaders + ", Signature=" + hex.EncodeToString(hmacSHA256(key, sts))
+
+		var diff bytes.Buffer
+		diffLines(&diff, "canonical request", debug.CanonicalRequest, test.canonical)
+		diffLines(&diff, "string to sign", debug.StringToSign, sts)
+		diffLines(&diff, "authorization", debug.Authorization, authz)
+		if diff.Len() > 0 {
+			t.Errorf("%s:\n%s", test.method, diff.String())
 		}
 	}
 }
@@ -191,7 +416,6 @@ func BenchmarkSignatureSign(b *testing.B) {
 		b.StopTimer()
 		rawRequest := []byte(`POST / HTTP/1.1
 Content-Type:application/x-www-form-urlencoded
-Date:Mon, 09 Sep 2011 23:36:00 GMT
 Host:host.foo.com
 
 foo=bar`)
@@ -287,3 +511,168 @@ secret	access`,
 		}
 	}
 }
+
+func TestSignSessionToken(t *testing.T) {
+	date := time.Date(2011, time.September, 9, 0, 0, 0, 0, time.UTC)
+	signature := &Signature{
+		AccessID:     "AKIDEXAMPLE",
+		Date:         date.Format(ISO8601BasicFormatShort),
+		Region:       USEast1,
+		Service:      "host",
+		SessionToken: "AQoDYXdzEPT//////////wEXAMPLE",
+	}
+	signature.generateSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Date", "Mon, 09 Sep 2011 23:36:00 GMT")
+
+	err = signature.Sign(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.Header.Get("X-Amz-Security-Token"); got != signature.SessionToken {
+		t.Error("X-Amz-Security-Token is", got)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/host/aws4_request, " +
+		"SignedHeaders=date;host;x-amz-security-token, " +
+		"Signature=6a846ff07fa32bd2a36009fb63a19f3b731063b5873eb987f750ef3ed6905575"
+	if got := request.Header.Get("Authorization"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+type expiringProvider struct {
+	calls      int
+	expiration time.Time
+}
+
+func (p *expiringProvider) Credentials() (*Credentials, error) {
+	p.calls++
+	return &Credentials{
+		AccessID:     "access",
+		Secret:       "secret",
+		SessionToken: fmt.Sprint("token", p.calls),
+		Expiration:   p.expiration,
+	}, nil
+}
+
+func TestSignRefreshesExpiringCredentials(t *testing.T) {
+	p := &expiringProvider{expiration: time.Now().Add(time.Hour)}
+	signature, err := NewSignatureFromProvider(p, USEast1, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.SessionToken != "token1" || !signature.Expiration.Equal(p.expiration) {
+		t.Fatal("credentials not set from provider")
+	}
+
+	sign := func() string {
+		request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signature.Sign(request, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request.Header.Get("X-Amz-Security-Token")
+	}
+
+	// Far from expiring, the credentials are reused.
+	if token := sign(); token != "token1" || p.calls != 1 {
+		t.Error("credentials refreshed early", token, p.calls)
+	}
+
+	// Within the expiry window new credentials are requested.
+	signature.Expiration = time.Now().Add(ExpiryWindow / 2)
+	if token := sign(); token != "token2" || p.calls != 2 {
+		t.Error("credentials not refreshed", token, p.calls)
+	}
+}
+
+func TestSignNewKeys(t *testing.T) {
+	clock := &testClock{now: time.Date(2011, time.September, 9, 23, 59, 59, 0, time.UTC)}
+	signature := NewSignature("secret", "access", USEast1, "service")
+	signature.Clock = clock
+	var calls int
+	signature.NewKeys = func() (string, string) {
+		calls++
+		return "access" + strconv.Itoa(calls), "secret" + strconv.Itoa(calls)
+	}
+
+	sign := func() string {
+		request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signature.Sign(request, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request.Header.Get("Authorization")
+	}
+
+	// The signature was created today, so the clock's date is a new day.
+	if auth := sign(); calls != 1 || !strings.Contains(auth, "Credential=access1/20110909/") {
+		t.Error("NewKeys not used", calls, auth)
+	}
+	if auth := sign(); calls != 1 {
+		t.Error("NewKeys called on the same day", calls, auth)
+	}
+
+	// And again when the date rolls over.
+	clock.Add(2 * time.Second)
+	if auth := sign(); calls != 2 || !strings.Contains(auth, "Credential=access2/20110910/") {
+		t.Error("NewKeys not used after midnight", calls, auth)
+	}
+	if signature.SigningKey != deriveSigningKey("secret2", "20110910", "us-east-1", "service") {
+		t.Error("signing key not derived from the new secret")
+	}
+}
+
+func TestSignDate(t *testing.T) {
+	date := time.Date(2011, time.September, 9, 0, 0, 0, 0, time.UTC)
+	signature := &Signature{
+		AccessID: "AKIDEXAMPLE",
+		Date:     date.Format(ISO8601BasicFormatShort),
+		Region:   USEast1,
+		Service:  "host",
+	}
+	signature.generateSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	// X-Amz-Date is preferred over Date.
+	request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Date", "Mon, 12 Sep 2011 23:36:00 GMT")
+	request.Header.Set("X-Amz-Date", "20110909T233600Z")
+	err = signature.Sign(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/host/aws4_request, " +
+		"SignedHeaders=date;host;x-amz-date, " +
+		"Signature=6df507a382942c09ea1ccf1389041cf72f2afa4d9006895055e3397c108b6238"
+	if got := request.Header.Get("Authorization"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Signing again replaces the signature rather than signing it.
+	err = signature.Sign(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := request.Header["Authorization"]; len(got) != 1 || got[0] != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The date must be on the signing key's day.
+	request.Header.Set("X-Amz-Date", "20110910T000000Z")
+	if err := signature.Sign(request, nil); err != ErrDateMismatch {
+		t.Error("expected ErrDateMismatch, got", err)
+	}
+}
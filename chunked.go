@@ -0,0 +1,166 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultChunkSize is the size of the chunks a ChunkedPayload is signed
+	// in if no size is given.
+	DefaultChunkSize = 64 << 10
+
+	// streamingPayloadHash replaces the payload hash in the canonical request
+	// of a chunked request.
+	streamingPayloadHash = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// chunkedPayload is a Payload that is signed as it is sent.
+type chunkedPayload struct {
+	r         io.Reader
+	length    int64
+	chunkSize int
+
+	// Set by Sign after signing the request.
+	signingKey [sha256.Size]byte
+	dateTime   string
+	credential string
+	previous   []byte // signature of the previous chunk
+
+	buf   []byte
+	frame bytes.Buffer
+	done  bool
+}
+
+// ChunkedPayload returns a payload that reads r once, in chunks of chunkSize
+// (DefaultChunkSize if zero), and sends each as an aws-chunked frame signed by
+// chaining on the signature of the previous chunk, the first chaining on the
+// request's signature. Only chunkSize bytes are held in memory at a time.
+//
+// Length is the total length of the data in r, or -1 if it is unknown in
+// which case the request's Content-Length is unknown too. Some services, such
+// as S3, require the length.
+//
+// See
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+func ChunkedPayload(r io.Reader, length int64, chunkSize int) Payload {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &chunkedPayload{r: r, length: length, chunkSize: chunkSize}
+}
+
+// chunkLength returns the encoded length of a chunk of n bytes.
+func chunkLength(n int64) int64 {
+	// hex(n) ";chunk-signature=" signature "\r\n" data "\r\n"
+	return int64(len(strconv.FormatInt(n, 16))) + 17 + 2*sha256.Size + 2 + n + 2
+}
+
+// encodedLength returns the encoded length of the payload including the final
+// empty chunk, or -1 if the payload's length is unknown.
+func (v *chunkedPayload) encodedLength() int64 {
+	if v.length < 0 {
+		return -1
+	}
+	size := int64(v.chunkSize)
+	full := v.length / size
+	encoded := full*chunkLength(size) + chunkLength(0)
+	if rest := v.length % size; rest > 0 {
+		encoded += chunkLength(rest)
+	}
+	return encoded
+}
+
+// setHeaders sets the headers of a chunked request, which have to be signed.
+func (v *chunkedPayload) setHeaders(r *http.Request) {
+	r.Header.Set("Content-Encoding", "aws-chunked")
+	r.Header.Set("X-Amz-Content-Sha256", streamingPayloadHash)
+	r.ContentLength = v.encodedLength()
+	if v.length >= 0 {
+		r.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(v.length, 10))
+	}
+}
+
+// seed sets the signing key, date, credential scope, and the request's
+// signature which the chunk signatures are chained from.
+func (v *chunkedPayload) seed(key [sha256.Size]byte, dateTime time.Time, credential string, signature []byte) {
+	v.signingKey = key
+	v.dateTime = dateTime.Format(ISO8601BasicFormat)
+	v.credential = credential
+	v.previous = signature
+}
+
+func (v *chunkedPayload) Payload() (io.ReadCloser, []byte, error) {
+	return ioutil.NopCloser(v), nil, nil
+}
+
+// Read reads the encoded chunks.
+func (v *chunkedPayload) Read(p []byte) (int, error) {
+	for v.frame.Len() == 0 {
+		if v.done {
+			return 0, io.EOF
+		}
+		err := v.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return v.frame.Read(p)
+}
+
+// nextFrame reads the next chunk and encodes it into frame. The final frame
+// is empty.
+func (v *chunkedPayload) nextFrame() error {
+	if v.buf == nil {
+		v.buf = make([]byte, v.chunkSize)
+	}
+	n, err := io.ReadFull(v.r, v.buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	data := v.buf[:n]
+	if n == 0 {
+		v.done = true
+	}
+
+	signature := v.chunkSignature(data)
+	v.frame.Reset()
+	v.frame.WriteString(strconv.FormatInt(int64(n), 16))
+	v.frame.WriteString(";chunk-signature=")
+	v.frame.Write(signature)
+	v.frame.WriteString("\r\n")
+	v.frame.Write(data)
+	v.frame.WriteString("\r\n")
+	v.previous = signature
+	return nil
+}
+
+// chunkSignature returns the signature of the chunk of data, which chains on
+// the previous signature.
+func (v *chunkedPayload) chunkSignature(data []byte) []byte {
+	var sts bytes.Buffer // string to sign
+	sts.WriteString("AWS4-HMAC-SHA256-PAYLOAD\n")
+	sts.WriteString(v.dateTime)
+	sts.WriteByte('\n')
+	sts.WriteString(v.credential)
+	sts.WriteByte('\n')
+	sts.Write(v.previous)
+	sts.WriteByte('\n')
+	sts.Write(emptyHash)
+	sts.WriteByte('\n')
+	hash := sha256.Sum256(data)
+	sts.Write(toHex(hash[:]))
+
+	h := hmac.New(sha256.New, v.signingKey[:])
+	h.Write(sts.Bytes())
+	return toHex(h.Sum(nil))
+}
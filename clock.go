@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Clock provides the current time to a Signature. Tests can replace the
+// system clock to sign requests deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock that uses time.Now.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// clock returns the signature's clock, the system clock if it doesn't have
+// one.
+func (s *Signature) clock() Clock {
+	if s.Clock == nil {
+		return SystemClock
+	}
+	return s.Clock
+}
+
+// now returns the UTC time requests are signed for, which is the clock's time
+// corrected by the clock offset. The caller must hold s.mu unless the
+// signature is not yet shared.
+func (s *Signature) now() time.Time {
+	return s.clock().Now().Add(s.clockOffset()).UTC()
+}
+
+// clockOffset returns the signature's clock offset, or that of the signature
+// it was created from by ForScope. The caller must hold s.mu, but not the
+// parent's, unless the signature is not yet shared.
+func (s *Signature) clockOffset() time.Duration {
+	if s.parent == nil {
+		return s.ClockOffset
+	}
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+	return s.parent.ClockOffset
+}
+
+// AdjustClock corrects the signature's clock if CorrectClockSkew is set and
+// err is the *Error AWS responded with because the request was signed for a
+// time too far from the server's. The offset is learned from the response's
+// Date header so later requests, and the signature's date and signing key
+// when the corrected time passes UTC midnight, use the server's time.
+//
+// Signatures created by ForScope share the clock offset of the signature they
+// were created from, so adjusting any of them adjusts them all.
+//
+// Returns whether the clock was adjusted, in which case the request can be
+// signed and sent again. The response may be nil, such as when the request
+// failed to be sent, in which case the clock isn't adjusted.
+func (s *Signature) AdjustClock(response *http.Response, err error) bool {
+	if response == nil || !s.CorrectClockSkew || !isClockSkewError(err) {
+		return false
+	}
+	serverTime, perr := http.ParseTime(response.Header.Get("Date"))
+	if perr != nil {
+		return false
+	}
+	if s.parent != nil {
+		s = s.parent
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ClockOffset = serverTime.Sub(s.clock().Now())
+	return true
+}
+
+// isClockSkewError returns whether err is an AWS error caused by signing a
+// request for the wrong time.
+func isClockSkewError(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code {
+	case "RequestTimeTooSkewed", "RequestExpired":
+		return true
+	case "InvalidSignatureException":
+		return strings.HasPrefix(e.Message, "Signature expired") ||
+			strings.HasPrefix(e.Message, "Signature not yet current")
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestSignatureClock(t *testing.T) {
+	clock := &testClock{now: time.Date(2011, time.September, 9, 23, 36, 0, 0, time.UTC)}
+	signature := NewSignature("secret", "access", USEast1, "service")
+	signature.Clock = clock
+
+	sign := func() *http.Request {
+		request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signature.Sign(request, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+
+	// The date and signing key follow the clock.
+	request := sign()
+	if got := request.Header.Get("X-Amz-Date"); got != "20110909T233600Z" {
+		t.Error("X-Amz-Date is", got)
+	}
+	if signature.Date != "20110909" ||
+		!strings.Contains(request.Header.Get("Authorization"), "Credential=access/20110909/us-east-1/service/") {
+		t.Error("unexpected authorization", request.Header.Get("Authorization"))
+	}
+	first := request.Header.Get("Authorization")
+	if got := sign().Header.Get("Authorization"); got != first {
+		t.Error("signing at the same time is not deterministic", got, first)
+	}
+
+	// Without skew correction errors don't change the clock.
+	response := &http.Response{Header: http.Header{"Date": {"Sat, 10 Sep 2011 00:10:00 GMT"}}}
+	skewed := &Error{Code: "RequestTimeTooSkewed", Type: "Client"}
+	if signature.AdjustClock(response, skewed) {
+		t.Error("clock adjusted without CorrectClockSkew")
+	}
+
+	// Only skew errors adjust the clock.
+	signature.CorrectClockSkew = true
+	if signature.AdjustClock(response, &Error{Code: "AccessDeniedException"}) {
+		t.Error("clock adjusted for another error")
+	}
+	if !signature.AdjustClock(response, skewed) {
+		t.Fatal("clock not adjusted")
+	}
+	if signature.ClockOffset != 34*time.Minute {
+		t.Error("clock offset is", signature.ClockOffset)
+	}
+
+	// The corrected time is past midnight so the date rolls over.
+	request = sign()
+	if got := request.Header.Get("X-Amz-Date"); got != "20110910T001000Z" {
+		t.Error("X-Amz-Date is", got)
+	}
+	if signature.Date != "20110910" ||
+		!strings.Contains(request.Header.Get("Authorization"), "Credential=access/20110910/us-east-1/service/") {
+		t.Error("unexpected authorization", request.Header.Get("Authorization"))
+	}
+
+	// Services that report skew as an invalid signature are recognized too.
+	signature.ClockOffset = 0
+	expired := &Error{Code: "InvalidSignatureException",
+		Message: "Signature expired: 20110909T233600Z is now earlier than 20110909T235500Z (20110910T001000Z - 15 min.)"}
+	if !signature.AdjustClock(response, expired) || signature.ClockOffset != 34*time.Minute {
+		t.Error("clock offset is", signature.ClockOffset)
+	}
+
+	// Without a response, such as after a transport error, there is no time
+	// to learn.
+	if signature.AdjustClock(nil, skewed) || signature.AdjustClock(nil, errors.New("connection refused")) {
+		t.Error("clock adjusted without a response")
+	}
+}
@@ -0,0 +1,241 @@
+package aws
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentials is returned by a provider when it has no credentials to
+// offer, for example when the environment variables it reads are unset.
+var ErrNoCredentials = errors.New("aws: no credentials found")
+
+// Credentials are the keys used to sign requests. SessionToken is only set for
+// temporary credentials and Expiration is the zero time if the credentials
+// don't expire.
+type Credentials struct {
+	AccessID     string
+	Secret       string
+	SessionToken string
+	Expiration   time.Time
+}
+
+// expiring reports whether expiration is within window of now. The zero
+// expiration never expires.
+func expiring(expiration, now time.Time, window time.Duration) bool {
+	return !expiration.IsZero() && !now.Add(window).Before(expiration)
+}
+
+// A CredentialsProvider is a source of credentials. Providers are asked for
+// credentials when a Signature is created and again whenever the signing key
+// has to be derived anew.
+type CredentialsProvider interface {
+	Credentials() (*Credentials, error)
+}
+
+// StaticProvider provides fixed credentials.
+type StaticProvider Credentials
+
+// Credentials returns a copy of the static credentials, or ErrNoCredentials if
+// either key is empty.
+func (p *StaticProvider) Credentials() (*Credentials, error) {
+	if p.AccessID == "" || p.Secret == "" {
+		return nil, ErrNoCredentials
+	}
+	c := Credentials(*p)
+	return &c, nil
+}
+
+// EnvProvider provides credentials from the environment variables
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN. The older
+// AWS_ACCESS_KEY and AWS_SECRET_KEY are used if the former are unset.
+type EnvProvider struct{}
+
+// Credentials returns the credentials from the environment, or
+// ErrNoCredentials if either key is unset.
+func (EnvProvider) Credentials() (*Credentials, error) {
+	var c Credentials
+	c.AccessID = os.Getenv("AWS_ACCESS_KEY_ID")
+	if c.AccessID == "" {
+		c.AccessID = os.Getenv("AWS_ACCESS_KEY")
+	}
+	c.Secret = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if c.Secret == "" {
+		c.Secret = os.Getenv("AWS_SECRET_KEY")
+	}
+	if c.AccessID == "" || c.Secret == "" {
+		return nil, ErrNoCredentials
+	}
+	c.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	return &c, nil
+}
+
+// FileProvider provides credentials from a file containing the secret and
+// access keys (in that order) separated by whitespace, see KeysFromFile.
+type FileProvider struct {
+	Filename string
+}
+
+// Credentials reads the credentials from the file. It returns
+// ErrNoCredentials if the file does not exist.
+func (p *FileProvider) Credentials() (*Credentials, error) {
+	secret, access, err := KeysFromFile(p.Filename)
+	if os.IsNotExist(err) {
+		return nil, ErrNoCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{AccessID: access, Secret: secret}, nil
+}
+
+// ChainProvider asks each of its providers for credentials in turn and returns
+// the first credentials found.
+type ChainProvider struct {
+	Providers []CredentialsProvider
+}
+
+// NewChainProvider returns a ChainProvider that tries the providers in order.
+func NewChainProvider(providers ...CredentialsProvider) *ChainProvider {
+	return &ChainProvider{Providers: providers}
+}
+
+// Credentials returns the credentials of the first provider to offer them.
+// Providers that fail are skipped. If none of the providers have credentials
+// ErrNoCredentials is returned, or the errors of the providers that failed for
+// reasons other than having no credentials.
+func (p *ChainProvider) Credentials() (*Credentials, error) {
+	var errs []string
+	for _, provider := range p.Providers {
+		c, err := provider.Credentials()
+		if err == nil {
+			return c, nil
+		}
+		if err != ErrNoCredentials {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New("aws: no credentials found: " + strings.Join(errs, "; "))
+	}
+	return nil, ErrNoCredentials
+}
+
+// SharedCredentialsProvider provides credentials from a profile in the shared
+// credentials and config files, see LoadProfileFiles.
+type SharedCredentialsProvider struct {
+	// Profile is the name of the profile. If empty the AWS_PROFILE
+	// environment variable is used, or "default" if it is unset.
+	Profile string
+
+	// Filename and ConfigFilename are the shared credentials and config
+	// files. If empty the AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE
+	// environment variables are used, or ~/.aws/credentials and ~/.aws/config
+	// if they are unset.
+	Filename       string
+	ConfigFilename string
+
+	mu        sync.Mutex
+	processes map[string]*CachedProvider // by credential_process command
+}
+
+// Credentials returns the profile's credentials. If the profile doesn't have
+// keys but has a credential_process its command is run to get them, see
+// NewProcessProvider, and they are cached until they are about to expire. It
+// returns ErrNoCredentials if the profile doesn't exist or has neither.
+func (p *SharedCredentialsProvider) Credentials() (*Credentials, error) {
+	profile, err := LoadProfileFiles(p.Profile, p.Filename, p.ConfigFilename)
+	if err == ErrProfileNotFound {
+		return nil, ErrNoCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if profile.AccessID == "" || profile.Secret == "" {
+		if command := profile.Values["credential_process"]; command != "" {
+			return p.process(command).Credentials()
+		}
+		return nil, ErrNoCredentials
+	}
+	c := profile.Credentials
+	return &c, nil
+}
+
+// process returns the provider running the credential process command,
+// reusing it across calls so its credentials are cached.
+func (p *SharedCredentialsProvider) process(command string) *CachedProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.processes == nil {
+		p.processes = make(map[string]*CachedProvider)
+	}
+	provider := p.processes[command]
+	if provider == nil {
+		provider = NewProcessProvider(command)
+		p.processes[command] = provider
+	}
+	return provider
+}
+
+// DefaultChainProvider returns a ChainProvider that tries the environment
+// variables, the shared credentials and config files, the ECS container
+// credentials, and then the EC2 instance metadata.
+func DefaultChainProvider() *ChainProvider {
+	return NewChainProvider(EnvProvider{}, &SharedCredentialsProvider{},
+		NewContainerProvider(), NewInstanceMetadataProvider())
+}
+
+// CachedProvider caches the credentials of another provider. The provider is
+// only asked for new credentials when the cached credentials are within Window
+// of expiring, so temporary credentials are refreshed before they lapse. It is
+// safe for concurrent use.
+//
+// Providers that fetch credentials from a service or a command do so on every
+// call to Credentials, so are wrapped in a CachedProvider by their
+// constructors, such as NewInstanceMetadataProvider.
+type CachedProvider struct {
+	Provider CredentialsProvider
+
+	// Window is how long before the credentials expire that new ones are
+	// requested. If zero ExpiryWindow is used.
+	Window time.Duration
+
+	mu          sync.Mutex
+	credentials *Credentials
+}
+
+// NewCachedProvider returns a CachedProvider for the provider.
+func NewCachedProvider(p CredentialsProvider) *CachedProvider {
+	return &CachedProvider{Provider: p}
+}
+
+// Credentials returns a copy of the cached credentials, first requesting new
+// ones from the provider if there are none or they are about to expire.
+func (p *CachedProvider) Credentials() (*Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	window := p.Window
+	if window == 0 {
+		window = ExpiryWindow
+	}
+	if p.credentials == nil || expiring(p.credentials.Expiration, time.Now(), window) {
+		c, err := p.Provider.Credentials()
+		if err != nil {
+			return nil, err
+		}
+		p.credentials = c
+	}
+	c := *p.credentials
+	return &c, nil
+}
+
+// Expire discards the cached credentials so new ones are requested by the
+// next call to Credentials.
+func (p *CachedProvider) Expire() {
+	p.mu.Lock()
+	p.credentials = nil
+	p.mu.Unlock()
+}
@@ -0,0 +1,177 @@
+package aws
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvProvider(t *testing.T) {
+	for _, v := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY",
+		"AWS_ACCESS_KEY", "AWS_SECRET_KEY", "AWS_SESSION_TOKEN"} {
+		t.Setenv(v, "")
+	}
+
+	if _, err := (EnvProvider{}).Credentials(); err != ErrNoCredentials {
+		t.Fatal("expected ErrNoCredentials, got", err)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY", "old access")
+	t.Setenv("AWS_SECRET_KEY", "old secret")
+	c, err := EnvProvider{}.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "old access" || c.Secret != "old secret" {
+		t.Error("unexpected credentials", c)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "token")
+	c, err = EnvProvider{}.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "access" || c.Secret != "secret" || c.SessionToken != "token" {
+		t.Error("unexpected credentials", c)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	p := &FileProvider{Filename: filepath.Join(dir, "keys")}
+
+	if _, err := p.Credentials(); err != ErrNoCredentials {
+		t.Fatal("expected ErrNoCredentials, got", err)
+	}
+
+	err := ioutil.WriteFile(p.Filename, []byte("secret\naccess\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "access" || c.Secret != "secret" {
+		t.Error("unexpected credentials", c)
+	}
+}
+
+type errorProvider struct {
+	err error
+}
+
+func (p errorProvider) Credentials() (*Credentials, error) {
+	return nil, p.err
+}
+
+func TestChainProvider(t *testing.T) {
+	first := &StaticProvider{AccessID: "first", Secret: "secret"}
+	second := &StaticProvider{AccessID: "second", Secret: "secret"}
+
+	c, err := NewChainProvider(&StaticProvider{}, first, second).Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "first" {
+		t.Error("got credentials from", c.AccessID)
+	}
+
+	_, err = NewChainProvider(&StaticProvider{}, errorProvider{ErrNoCredentials}).Credentials()
+	if err != ErrNoCredentials {
+		t.Error("expected ErrNoCredentials, got", err)
+	}
+
+	_, err = NewChainProvider(errorProvider{errors.New("broken")}).Credentials()
+	if err == nil || err == ErrNoCredentials {
+		t.Error("expected provider's error, got", err)
+	}
+
+	c, err = NewChainProvider(errorProvider{errors.New("broken")}, second).Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "second" {
+		t.Error("got credentials from", c.AccessID)
+	}
+}
+
+type countingProvider struct {
+	StaticProvider
+	calls int
+}
+
+func (p *countingProvider) Credentials() (*Credentials, error) {
+	p.calls++
+	return p.StaticProvider.Credentials()
+}
+
+func TestSignatureProvider(t *testing.T) {
+	p := &countingProvider{StaticProvider: StaticProvider{AccessID: "access", Secret: "secret"}}
+	s, err := NewSignatureFromProvider(p, USEast1, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AccessID != "access" || p.calls != 1 {
+		t.Fatal("unexpected signature", s.AccessID, p.calls)
+	}
+	want := s.SigningKey
+
+	// Simulate the date changing, the provider should be asked for new keys.
+	s.Date = "20110909"
+	s.SigningKey = [32]byte{}
+	p.AccessID = "new access"
+	request, err := http.NewRequest("GET", "https://host.foo.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Sign(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.calls != 2 || s.AccessID != "new access" || s.SigningKey != want {
+		t.Error("signing key not refreshed from provider")
+	}
+
+	_, err = NewSignatureFromProvider(&StaticProvider{}, USEast1, "service")
+	if err != ErrNoCredentials {
+		t.Error("expected ErrNoCredentials, got", err)
+	}
+
+	s.Date = "20110909"
+	s.Provider = errorProvider{os.ErrPermission}
+	if err := s.Sign(request, nil); err != os.ErrPermission {
+		t.Error("expected provider's error, got", err)
+	}
+}
+
+func TestCachedProvider(t *testing.T) {
+	p := &countingProvider{StaticProvider: StaticProvider{AccessID: "access", Secret: "secret",
+		Expiration: time.Now().Add(time.Hour)}}
+	cached := NewCachedProvider(p)
+	for i := 0; i < 3; i++ {
+		if _, err := cached.Credentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.calls != 1 {
+		t.Error("provider called", p.calls, "times")
+	}
+
+	p.Expiration = time.Now().Add(ExpiryWindow / 2)
+	cached.Expire()
+	for i := 0; i < 3; i++ {
+		if _, err := cached.Credentials(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.calls != 4 {
+		t.Error("expiring credentials not refreshed", p.calls)
+	}
+}
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SigningDebug is what was signed by SignWithDebug. When AWS responds with a
+// signature error it can be compared with what AWS calculated, see Diff.
+type SigningDebug struct {
+	CanonicalRequest string
+	StringToSign     string
+	SignedHeaders    []string
+	Authorization    string
+}
+
+// SignWithDebug signs the request like Sign and returns the canonical
+// request, string to sign, signed headers, and Authorization header it used.
+func (s *Signature) SignWithDebug(r *http.Request, payload Payload) (*SigningDebug, error) {
+	debug := new(SigningDebug)
+	err := s.sign(r, payload, debug)
+	if err != nil {
+		return nil, err
+	}
+	return debug, nil
+}
+
+// Diff compares what was signed with the canonical request and string to
+// sign AWS expected, which are parsed from the message of a signature error
+// such as SignatureDoesNotMatch. It returns the lines that differ, or an
+// empty string if there are no differences or the error doesn't include what
+// AWS expected.
+func (d *SigningDebug) Diff(e *Error) string {
+	var diff bytes.Buffer
+	if e.CanonicalRequest != "" {
+		diffLines(&diff, "canonical request", d.CanonicalRequest, e.CanonicalRequest)
+	}
+	if e.StringToSign != "" {
+		diffLines(&diff, "string to sign", d.StringToSign, e.StringToSign)
+	}
+	return diff.String()
+}
+
+// diffLines writes the lines of got and want that differ to diff.
+func diffLines(diff *bytes.Buffer, name, got, want string) {
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+	n := len(gotLines)
+	if len(wantLines) > n {
+		n = len(wantLines)
+	}
+	for i := 0; i < n; i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			fmt.Fprintf(diff, "%s line %d:\n\tsigned:   %q\n\texpected: %q\n", name, i+1, g, w)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWithDebug(t *testing.T) {
+	date := time.Date(2011, time.September, 9, 0, 0, 0, 0, time.UTC)
+	signature := &Signature{
+		AccessID: "AKIDEXAMPLE",
+		Date:     date.Format(ISO8601BasicFormatShort),
+		Region:   USEast1,
+		Service:  "host",
+	}
+	signature.generateSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Date", "Mon, 09 Sep 2011 23:36:00 GMT")
+
+	debug, err := signature.SignWithDebug(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical := "GET\n/\n\ndate:Mon, 09 Sep 2011 23:36:00 GMT\nhost:host.foo.com\n\ndate;host\n" + string(emptyHash)
+	if debug.CanonicalRequest != canonical {
+		t.Errorf("canonical request:\n%s\nwant:\n%s", debug.CanonicalRequest, canonical)
+	}
+	sts := "AWS4-HMAC-SHA256\n20110909T233600Z\n20110909/us-east-1/host/aws4_request\n" +
+		"366b91fb121d72a00f46bbe8d395f53a102b06dfb7e79636515208ed3fa606b1"
+	if debug.StringToSign != sts {
+		t.Errorf("string to sign:\n%s\nwant:\n%s", debug.StringToSign, sts)
+	}
+	if strings.Join(debug.SignedHeaders, ";") != "date;host" {
+		t.Error("signed headers", debug.SignedHeaders)
+	}
+	if debug.Authorization != request.Header.Get("Authorization") ||
+		!strings.HasSuffix(debug.Authorization, "Signature=b27ccfbfa7df52a200ff74193ca6e32d4b48b8856fab7ebf1c595d0670a7e470") {
+		t.Error("authorization", debug.Authorization)
+	}
+
+	// AWS calculated the canonical request with a different host.
+	expected := strings.Replace(canonical, "host:host.foo.com", "host:host.bar.com", 1)
+	message, err := json.Marshal(map[string]string{
+		"code": "SignatureDoesNotMatch",
+		"type": "Client",
+		"message": "The request signature we calculated does not match the signature you provided. " +
+			"Check your AWS Secret Access Key and signing method. Consult the service documentation for details.\n\n" +
+			"The Canonical String for this request should have been\n'" + expected + "'\n\n" +
+			"The String-to-Sign should have been\n'" + sts + "'\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := &http.Response{Body: ioutil.NopCloser(strings.NewReader(string(message)))}
+	e, ok := ParseError(response).(*Error)
+	if !ok {
+		t.Fatal("expected *Error")
+	}
+	if e.CanonicalRequest != expected || e.StringToSign != sts {
+		t.Errorf("unexpected error %+v", e)
+	}
+
+	// Glacier's code differs but its message is the same.
+	message = []byte(strings.Replace(string(message), "SignatureDoesNotMatch", "InvalidSignatureException", 1))
+	response = &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header:     http.Header{"X-Amzn-Requestid": {"AAAABBBB"}},
+		Body:       ioutil.NopCloser(strings.NewReader(string(message))),
+	}
+	glacierErr, ok := ParseError(response).(*Error)
+	if !ok {
+		t.Fatal("expected *Error")
+	}
+	if glacierErr.Code != "InvalidSignatureException" || glacierErr.CanonicalRequest != expected ||
+		glacierErr.StringToSign != sts {
+		t.Errorf("unexpected Glacier error %+v", glacierErr)
+	}
+
+	want := "canonical request line 5:\n" +
+		"\tsigned:   \"host:host.foo.com\"\n" +
+		"\texpected: \"host:host.bar.com\"\n"
+	if diff := debug.Diff(e); diff != want {
+		t.Errorf("diff:\n%s\nwant:\n%s", diff, want)
+	}
+}
@@ -2,33 +2,281 @@ package aws
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
 )
 
+// Error is an error response from AWS. Code identifies the error, such as
+// ThrottlingException, and Type is whether the client (Client or Sender) or
+// the server (Server) is at fault.
+//
+// Errors with the same code are equal as far as errors.Is is concerned, so
+// the sentinel values, such as ErrThrottling, can be compared with an error
+// parsed from a response.
 type Error struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Type    string `json:"type"`
+
+	// The HTTP status code of the response, the request ID AWS assigned to the
+	// request, which AWS support asks for, and the raw body of the response.
+	StatusCode int    `json:"-"`
+	RequestID  string `json:"-"`
+	Body       []byte `json:"-"`
+
+	// For signature errors, such as SignatureDoesNotMatch and
+	// InvalidSignatureException, the canonical request and string to sign AWS
+	// calculated if they were included in the message. Compare them
+	// with what was signed using SignWithDebug.
+	CanonicalRequest string `json:"-"`
+	StringToSign     string `json:"-"`
 }
 
 func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return e.Code + ": " + e.Type + ": " + e.Message + " (request ID " + e.RequestID + ")"
+	}
 	return e.Code + ": " + e.Type + ": " + e.Message
 }
 
+// Is returns whether target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// Errors AWS commonly responds with, to compare with errors.Is. Only their
+// codes are compared, an error from a service that uses a different code for
+// the same thing, such as S3's NoSuchKey, is not one of these. Use IsNotFound,
+// IsThrottle, and IsRetryable to check for any of them.
+var (
+	// ErrAccessDenied is the error for a request the credentials aren't
+	// authorized to make.
+	ErrAccessDenied = &Error{Code: "AccessDeniedException", Type: "Client",
+		Message: "Access denied."}
+
+	// ErrResourceNotFound is the error for a request for a resource, such as a
+	// vault or job, that doesn't exist.
+	ErrResourceNotFound = &Error{Code: "ResourceNotFoundException", Type: "Client",
+		Message: "The specified resource doesn't exist."}
+
+	// ErrThrottling is the error for a request that was sent too soon after
+	// others.
+	ErrThrottling = &Error{Code: "ThrottlingException", Type: "Client",
+		Message: "Rate exceeded."}
+
+	// ErrServiceUnavailable is the error for a request the service failed to
+	// handle.
+	ErrServiceUnavailable = &Error{Code: "ServiceUnavailableException", Type: "Server",
+		Message: "Service is unavailable. Try again later."}
+)
+
+// notFoundCodes are the codes of the errors for a resource that doesn't exist.
+var notFoundCodes = map[string]bool{
+	"NoSuchBucket":              true,
+	"NoSuchEntity":              true,
+	"NoSuchKey":                 true,
+	"NoSuchUpload":              true,
+	"NotFound":                  true,
+	"ResourceNotFoundException": true,
+}
+
+// throttlingCodes are the codes of the errors for requests sent too fast.
+var throttlingCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"RequestThrottled":                       true,
+	"SlowDown":                               true,
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"TooManyRequestsException":               true,
+}
+
+// retryableCodes are the codes of the errors, besides throttling, for
+// requests that may succeed if sent again.
+var retryableCodes = map[string]bool{
+	"InternalError":               true,
+	"InternalFailure":             true,
+	"RequestTimeout":              true,
+	"RequestTimeoutException":     true,
+	"ServiceUnavailable":          true,
+	"ServiceUnavailableException": true,
+}
+
+// IsNotFound returns whether err is, or wraps, an AWS error for a resource
+// that doesn't exist.
+func IsNotFound(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return notFoundCodes[e.Code] || e.StatusCode == http.StatusNotFound
+}
+
+// IsThrottle returns whether err is, or wraps, an AWS error for a request that
+// was throttled.
+func IsThrottle(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return throttlingCodes[e.Code] || e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsRetryable returns whether the request that failed with err may succeed if
+// sent again unchanged. That is if err is, or wraps, an AWS error for a
+// throttled request, an internal error or timeout, or a response with a 408,
+// 429, 500, 502, 503, or 504 status code. Or if err is the *url.Error of an
+// http.Client that failed to send the request because the connection was
+// reset or closed before any response was read. Other errors, such as
+// timeouts, may have happened after AWS made the request so are not
+// retryable. Only idempotent requests should be retried either way.
+//
+// Errors caused by the signing time, such as RequestTimeTooSkewed, are not
+// retryable unless the clock is corrected first, see Signature.AdjustClock.
+func IsRetryable(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		if throttlingCodes[e.Code] || retryableCodes[e.Code] {
+			return true
+		}
+		switch e.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	return errors.Is(urlErr, syscall.ECONNRESET) || errors.Is(urlErr, io.EOF) ||
+		errors.Is(urlErr, io.ErrUnexpectedEOF)
+}
+
 // Attempts to parse an AWS error out of a http response, it is still the
 // caller's responsibility to close the body. See
 // http://docs.aws.amazon.com/amazonglacier/latest/dev/api-error-responses.html
 //
+// The body may be JSON, as Glacier and most services respond with, or XML,
+// as S3 and STS respond with. Otherwise, such as the HTML of a proxy or an
+// empty body, the code is the status text without spaces, "BadGateway" or
+// "Forbidden", and the message is the body. Only an error reading the body is
+// returned as is, any other error is an *Error.
 func ParseError(response *http.Response) error {
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return err
 	}
-	awsErr := new(Error)
-	err = json.Unmarshal(body, awsErr)
-	if err != nil {
-		return err
+	awsErr := &Error{
+		StatusCode: response.StatusCode,
+		RequestID:  requestID(response.Header),
+		Body:       body,
+	}
+	if !parseJSONError(body, awsErr) && !parseXMLError(body, awsErr) {
+		awsErr.Code = strings.Replace(http.StatusText(response.StatusCode), " ", "", -1)
+		if awsErr.Message == "" {
+			awsErr.Message = strings.TrimSpace(string(body))
+		}
+		if awsErr.Message == "" {
+			awsErr.Message = response.Status
+		}
+	}
+	if awsErr.Type == "" {
+		awsErr.Type = "Client"
+		if response.StatusCode >= 500 {
+			awsErr.Type = "Server"
+		}
+	}
+	// S3 and the query services use SignatureDoesNotMatch, and Glacier and the
+	// other JSON services InvalidSignatureException, with the same message.
+	if strings.Contains(awsErr.Message, "should have been\n") {
+		awsErr.CanonicalRequest = quotedAfter(awsErr.Message,
+			"The Canonical String for this request should have been\n")
+		awsErr.StringToSign = quotedAfter(awsErr.Message,
+			"The String-to-Sign should have been\n")
 	}
 	return awsErr
 }
+
+// requestID returns the request ID from the response's headers.
+func requestID(header http.Header) string {
+	if id := header.Get("X-Amzn-Requestid"); id != "" {
+		return id
+	}
+	return header.Get("X-Amz-Request-Id")
+}
+
+// parseJSONError parses a JSON error body into e, and returns whether it has
+// an error code. Services using the JSON protocol name the code __type,
+// possibly prefixed by a namespace and "#".
+func parseJSONError(body []byte, e *Error) bool {
+	var parsed struct {
+		Code     string `json:"code"`
+		Message  string `json:"message"`
+		Type     string `json:"type"`
+		JSONType string `json:"__type"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return false
+	}
+	if parsed.Code == "" {
+		parsed.Code = parsed.JSONType[strings.LastIndex(parsed.JSONType, "#")+1:]
+	}
+	e.Code, e.Message, e.Type = parsed.Code, parsed.Message, parsed.Type
+	return e.Code != ""
+}
+
+// xmlError is an error in an XML error body.
+type xmlError struct {
+	Type      string
+	Code      string
+	Message   string
+	RequestId string
+}
+
+// parseXMLError parses an XML error body, either an Error element or one
+// wrapped in an ErrorResponse element, into e, and returns whether it has an
+// error code.
+func parseXMLError(body []byte, e *Error) bool {
+	var parsed struct {
+		xmlError
+		Error xmlError
+	}
+	if xml.Unmarshal(body, &parsed) != nil {
+		return false
+	}
+	if parsed.Code == "" {
+		parsed.Type, parsed.Code, parsed.Message = parsed.Error.Type, parsed.Error.Code, parsed.Error.Message
+	}
+	e.Code, e.Message, e.Type = parsed.Code, parsed.Message, parsed.Type
+	if e.RequestID == "" {
+		e.RequestID = parsed.RequestId
+	}
+	return e.Code != ""
+}
+
+// quotedAfter returns the single quoted, possibly multi-line, string following
+// prefix in the message, or an empty string if there isn't one.
+func quotedAfter(message, prefix string) string {
+	i := strings.Index(message, prefix+"'")
+	if i < 0 {
+		return ""
+	}
+	rest := message[i+len(prefix)+1:]
+	end := strings.Index(rest, "'\n")
+	if end < 0 {
+		end = strings.LastIndex(rest, "'")
+		if end < 0 {
+			return ""
+		}
+	}
+	return rest[:end]
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func testResponse(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  *http.Response
+		code      string
+		typ       string
+		message   string
+		requestID string
+	}{
+		{
+			"json",
+			testResponse(http.StatusNotFound, http.Header{"X-Amzn-Requestid": {"AAAABBBB"}},
+				`{"code":"ResourceNotFoundException","message":"Vault not found","type":"Client"}`),
+			"ResourceNotFoundException", "Client", "Vault not found", "AAAABBBB",
+		},
+		{
+			"json protocol",
+			testResponse(http.StatusBadRequest, nil,
+				`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"Slow down"}`),
+			"ProvisionedThroughputExceededException", "Client", "Slow down", "",
+		},
+		{
+			"xml",
+			testResponse(http.StatusForbidden, nil, `<ErrorResponse><Error><Type>Sender</Type>`+
+				`<Code>AccessDenied</Code><Message>Denied</Message></Error><RequestId>1234</RequestId></ErrorResponse>`),
+			"AccessDenied", "Sender", "Denied", "1234",
+		},
+		{
+			"s3 xml",
+			testResponse(http.StatusNotFound, http.Header{"X-Amz-Request-Id": {"5678"}}, `<?xml version="1.0" encoding="UTF-8"?>`+
+				`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><RequestId>9999</RequestId></Error>`),
+			"NoSuchKey", "Client", "The specified key does not exist.", "5678",
+		},
+		{
+			"html",
+			testResponse(http.StatusBadGateway, nil, "<html><body>502 Bad Gateway</body></html>\n"),
+			"BadGateway", "Server", "<html><body>502 Bad Gateway</body></html>", "",
+		},
+		{
+			"empty",
+			testResponse(http.StatusForbidden, http.Header{"X-Amzn-Requestid": {"CCCC"}}, ""),
+			"Forbidden", "Client", "403 Forbidden", "CCCC",
+		},
+	}
+	for _, test := range tests {
+		err := ParseError(test.response)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected *Error, got %v", test.name, err)
+			continue
+		}
+		if e.Code != test.code || e.Type != test.typ || e.Message != test.message ||
+			e.RequestID != test.requestID || e.StatusCode != test.response.StatusCode || len(e.Body) == 0 && test.name != "empty" {
+			t.Errorf("%s: unexpected error %+v", test.name, e)
+		}
+	}
+}
+
+// sendError returns err as an http.Client returns it when sending a request
+// fails.
+func sendError(err error) error {
+	return &url.Error{Op: "Post", URL: "https://glacier.us-east-1.amazonaws.com/", Err: err}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorPredicates(t *testing.T) {
+	parse := func(status int, code string) error {
+		body := ""
+		if code != "" {
+			body = `{"code":"` + code + `","message":"message","type":"Client"}`
+		}
+		return fmt.Errorf("glacier: %w", ParseError(testResponse(status, nil, body)))
+	}
+
+	tests := []struct {
+		err                           error
+		notFound, throttle, retryable bool
+	}{
+		{parse(http.StatusNotFound, "ResourceNotFoundException"), true, false, false},
+		{parse(http.StatusNotFound, ""), true, false, false},
+		{parse(http.StatusBadRequest, "ThrottlingException"), false, true, true},
+		{parse(http.StatusTooManyRequests, ""), false, true, true},
+		{parse(http.StatusServiceUnavailable, "SlowDown"), false, true, true},
+		{parse(http.StatusInternalServerError, "InternalFailure"), false, false, true},
+		{parse(http.StatusBadGateway, ""), false, false, true},
+		{parse(http.StatusBadRequest, "InvalidParameterValueException"), false, false, false},
+		{parse(http.StatusForbidden, "RequestTimeTooSkewed"), false, false, false},
+		{sendError(syscall.ECONNRESET), false, false, true},
+		{sendError(io.EOF), false, false, true},
+		{sendError(io.ErrUnexpectedEOF), false, false, true},
+		{sendError(timeoutError{}), false, false, false},
+		{sendError(syscall.EPIPE), false, false, false},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), false, false, false},
+		{io.ErrUnexpectedEOF, false, false, false},
+		{errors.New("other"), false, false, false},
+		{nil, false, false, false},
+	}
+	for _, test := range tests {
+		if IsNotFound(test.err) != test.notFound || IsThrottle(test.err) != test.throttle ||
+			IsRetryable(test.err) != test.retryable {
+			t.Errorf("%v: not found %v, throttle %v, retryable %v", test.err,
+				IsNotFound(test.err), IsThrottle(test.err), IsRetryable(test.err))
+		}
+	}
+
+	err := parse(http.StatusBadRequest, "ThrottlingException")
+	if !errors.Is(err, ErrThrottling) || errors.Is(err, ErrResourceNotFound) {
+		t.Error("errors.Is with sentinels", err)
+	}
+	if !errors.Is(ErrSignatureDoesNotMatch, &Error{Code: "SignatureDoesNotMatch"}) ||
+		errors.Is(ErrSignatureDoesNotMatch, &Error{}) {
+		t.Error("errors.Is compares codes")
+	}
+}
@@ -1,29 +1,33 @@
 package glacier
 
 import (
+	"context"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"path"
-
-	"github.com/rdwilliamson/aws"
 )
 
 // Upload archive to vault with optional description. The entire archive will
-// be read in order to create its tree hash before uploading.
+// be read in order to create its tree hash before uploading. It is never
+// retried, as a retry after AWS stored the archive would store another.
 //
 // Returns the archive ID or the first error encountered.
 func (c *Connection) UploadArchive(vault string, archive io.ReadSeeker, description string) (string, error) {
+	return c.UploadArchiveContext(context.Background(), vault, archive, description)
+}
+
+// UploadArchiveContext is like UploadArchive but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter. Canceling the context
+// also stops reading the archive to hash it.
+func (c *Connection) UploadArchiveContext(ctx context.Context, vault string, archive io.ReadSeeker, description string) (string, error) {
 	// Build reuest.
-	request, err := http.NewRequest("POST", c.vault(vault)+"/archives",
-		archive)
+	r, err := c.newRequest(ctx, "UploadArchive", "POST", c.vault(vault)+"/archives")
 	if err != nil {
 		return "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
 	th := NewTreeHash()
-	request.ContentLength, err = io.Copy(th, archive)
+	r.HTTPRequest.ContentLength, err = io.Copy(th, contextReader{ctx, archive})
 	if err != nil {
 		return "", err
 	}
@@ -36,27 +40,21 @@ func (c *Connection) UploadArchive(vault string, archive io.ReadSeeker, descript
 
 	hash := th.Hash()
 
-	request.Header.Add("x-amz-archive-description", description)
-	request.Header.Add("x-amz-sha256-tree-hash", toHex(th.TreeHash()))
-	request.Header.Add("x-amz-content-sha256", toHex(hash))
+	r.HTTPRequest.Header.Add("x-amz-archive-description", description)
+	r.HTTPRequest.Header.Add("x-amz-sha256-tree-hash", toHex(th.TreeHash()))
+	r.HTTPRequest.Header.Add("x-amz-content-sha256", toHex(hash))
 
-	c.Signature.Sign(request, aws.HashedPayload(hash))
+	r.Payload = &rewindPayload{archive, hash}
+	r.Idempotent = false
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusCreated)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusCreated {
-		return "", aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
-	_, location := path.Split(response.Header.Get("Location"))
+	_, location := path.Split(r.HTTPResponse.Header.Get("Location"))
 	return location, nil
 }
 
@@ -64,27 +62,23 @@ func (c *Connection) UploadArchive(vault string, archive io.ReadSeeker, descript
 //
 // Returns the first error encountered.
 func (c *Connection) DeleteArchive(vault, archive string) error {
+	return c.DeleteArchiveContext(context.Background(), vault, archive)
+}
+
+// DeleteArchiveContext is like DeleteArchive but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) DeleteArchiveContext(ctx context.Context, vault, archive string) error {
 	// Build request.
-	request, err := http.NewRequest("DELETE", c.vault(vault)+"/archives/"+archive, nil)
+	r, err := c.newRequest(ctx, "DeleteArchive", "DELETE", c.vault(vault)+"/archives/"+archive)
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -2,9 +2,15 @@
 package glacier
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/rdwilliamson/aws"
 )
@@ -16,6 +22,37 @@ type Connection struct {
 	Client *http.Client
 
 	Signature *aws.Signature
+
+	// Endpoint optionally specifies the URL requests are sent to, such as
+	// "http://localhost:8080" for a local stand-in or a VPC or FIPS endpoint.
+	// If empty, "https://" followed by the signature's region's Glacier host
+	// is used. Requests are still signed for the signature's region.
+	Endpoint string
+
+	// AccountID optionally specifies the ID of the account that owns the
+	// vaults. If empty, "-" is used, the account of the signature's
+	// credentials.
+	AccountID string
+
+	// Retry optionally specifies how failed requests are retried. If nil,
+	// DefaultRetry is used.
+	Retry *Retry
+
+	// RateLimiter optionally limits how fast requests are sent. If nil, they
+	// are not limited.
+	RateLimiter *RateLimiter
+
+	// Logger optionally specifies where a record of every request sent is
+	// logged, see StageLog. If nil, requests are not logged.
+	Logger *slog.Logger
+
+	// Metrics optionally specifies where measurements of every operation are
+	// recorded. If nil, they are not measured.
+	Metrics MetricsSink
+
+	middleware [numStages][]Middleware // added by Use
+
+	sleep func(time.Duration) // replaces time.Sleep in tests
 }
 
 func (c *Connection) client() *http.Client {
@@ -25,14 +62,37 @@ func (c *Connection) client() *http.Client {
 	return c.Client
 }
 
+// parseError parses the AWS error out of the response. If the request was
+// rejected for being signed for the wrong time and the signature corrects
+// clock skew, later requests are signed with the server's time.
+func (c *Connection) parseError(response *http.Response) error {
+	err := aws.ParseError(response)
+	c.Signature.AdjustClock(response, err)
+	return err
+}
+
+// account returns the URL prefix of the connection's account, without a
+// trailing slash.
+func (c *Connection) account() string {
+	endpoint := strings.TrimSuffix(c.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://" + c.Signature.Region.Glacier
+	}
+	account := c.AccountID
+	if account == "" {
+		account = "-"
+	}
+	return endpoint + "/" + account
+}
+
 // vault returns the URL prefix of the named vault, without a trailing slash.
 func (c *Connection) vault(vault string) string {
-	return "https://" + c.Signature.Region.Glacier + "/-/vaults/" + vault
+	return c.account() + "/vaults/" + vault
 }
 
 // policy returns the URL prefix of the named policy, without a trailing slash.
 func (c *Connection) policy(policy string) string {
-	return "https://" + c.Signature.Region.Glacier + "/-/policies/" + policy
+	return c.account() + "/policies/" + policy
 }
 
 // NewConnection returns a Connection with an initialized signature
@@ -43,7 +103,52 @@ func NewConnection(secret, access string, r *aws.Region) *Connection {
 	}
 }
 
-// TODO method to log things such as x-amzn-RequestId
+// NewSessionConnection returns a Connection with an initialized signature
+// based on the provided temporary credentials and region.
+func NewSessionConnection(secret, access, token string, r *aws.Region) *Connection {
+	return &Connection{
+		Signature: aws.NewSessionSignature(secret, access, token, r, "glacier"),
+	}
+}
+
+// NewConnectionFromProvider returns a Connection with a signature initialized
+// from the provider's credentials for the region.
+func NewConnectionFromProvider(p aws.CredentialsProvider, r *aws.Region) (*Connection, error) {
+	signature, err := aws.NewSignatureFromProvider(p, r, "glacier")
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{Signature: signature}, nil
+}
+
+// NewConnectionFromProfile returns a Connection using the credentials and
+// region of the named profile in the shared credentials and config files. If
+// profile is empty the AWS_PROFILE environment variable is used, or "default"
+// if it is unset. See aws.LoadProfile.
+func NewConnectionFromProfile(profile string) (*Connection, error) {
+	p, err := aws.LoadProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if p.Region == nil {
+		return nil, errors.New("glacier: profile " + p.Name + " has no known region")
+	}
+	return NewConnectionFromProvider(&aws.SharedCredentialsProvider{Profile: p.Name}, p.Region)
+}
+
+// contextReader reads from r until ctx is done, then fails with ctx's error so
+// hashing a large body stops when the request is canceled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
 
 // toHex returns the lowercase hex encoding of x.
 func toHex(x []byte) string {
@@ -1,8 +1,18 @@
 package glacier
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rdwilliamson/aws"
 )
@@ -16,13 +26,7 @@ func testConnection(t *testing.T) *Connection {
 	if regionStr == "" {
 		t.Skipf("%s is not provided.", envGlacierRegion)
 	}
-	var region *aws.Region
-	for _, r := range aws.Regions {
-		if r.Name == regionStr {
-			region = r
-			break
-		}
-	}
+	region := aws.FindRegion(regionStr)
 	if region == nil {
 		t.Skipf("%s is invalid.", envGlacierRegion)
 	}
@@ -36,3 +40,133 @@ func testVault(t *testing.T) string {
 	}
 	return vault
 }
+
+func TestNewConnectionFromProfile(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	config := filepath.Join(dir, "config")
+	err := ioutil.WriteFile(credentials, []byte("[backup]\naws_access_key_id = access\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(config, []byte("[profile backup]\nregion = eu-central-1\n[profile nowhere]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", config)
+
+	c, err := NewConnectionFromProfile("backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Signature.AccessID != "access" || c.Signature.Region != aws.EU2 || c.Signature.Service != "glacier" {
+		t.Errorf("unexpected signature %+v", c.Signature)
+	}
+
+	if _, err = NewConnectionFromProfile("nowhere"); err == nil {
+		t.Error("expected error for profile without a region")
+	}
+}
+
+// cancelingReader cancels the context after n bytes have been read from it.
+type cancelingReader struct {
+	*bytes.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	if int64(r.Len()) <= r.Size()-int64(r.n) {
+		r.cancel()
+	}
+	return r.Reader.Read(p)
+}
+
+func TestContextCancelsHashing(t *testing.T) {
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent after cancel")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	body := &cancelingReader{Reader: bytes.NewReader(make([]byte, 1<<24)), n: 1 << 20, cancel: cancel}
+
+	_, err := c.UploadArchiveContext(ctx, "vault", body, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Error("expected context.Canceled, got", err)
+	}
+	if read := body.Size() - int64(body.Len()); read > 2<<20 {
+		t.Error(read, "bytes read after cancel")
+	}
+
+	body.Seek(0, io.SeekStart)
+	err = c.UploadMultipartContext(ctx, "vault", "upload", 0, body)
+	if !errors.Is(err, context.Canceled) {
+		t.Error("expected context.Canceled, got", err)
+	}
+}
+
+func TestContextCancelsRetries(t *testing.T) {
+	var attempts int
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, "ServiceUnavailableException")
+	})
+	c.Retry = &Retry{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	_, _, err := c.ListVaultsContext(ctx, "", 0)
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Error(attempts, "attempts, expected 1 and context.Canceled, got", err)
+	}
+
+	// A deadline applies to the backoff too.
+	c.sleep = nil
+	c.Retry = &Retry{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	attempts = 0
+	start := time.Now()
+	err = c.DeleteVaultContext(ctx, "vault")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Minute {
+		t.Error("expected context.DeadlineExceeded, got", err, "after", time.Since(start))
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := aws.Verify(r, testLookup); err != nil {
+			t.Error(r.URL.Path, err)
+		}
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := NewConnection("secret", "access", aws.USEast1)
+	c.Endpoint = server.URL + "/"
+	c.AccountID = "123456789012"
+	if err := c.DeleteVault("vault"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AbortMultipart("vault", "upload"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetRetrievalPolicy(BytesPerHour, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/123456789012/vaults/vault",
+		"/123456789012/vaults/vault/multipart-uploads/upload",
+		"/123456789012/policies/data-retrieval",
+	}
+	if strings.Join(paths, " ") != strings.Join(expected, " ") {
+		t.Error("paths", paths)
+	}
+
+	c.Endpoint, c.AccountID = "", ""
+	if v := c.vault("vault"); v != "https://glacier.us-east-1.amazonaws.com/-/vaults/vault" {
+		t.Error("default vault URL", v)
+	}
+}
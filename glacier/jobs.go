@@ -1,10 +1,10 @@
 package glacier
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
@@ -119,33 +119,32 @@ type job struct {
 //
 // Returns the job ID or the first error encountered.
 func (c *Connection) InitiateRetrievalJob(vault, archive, topic, description string) (string, error) {
+	return c.InitiateRetrievalJobContext(context.Background(), vault, archive, topic, description)
+}
+
+// InitiateRetrievalJobContext is like InitiateRetrievalJob but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) InitiateRetrievalJobContext(ctx context.Context, vault, archive, topic, description string) (string, error) {
 	// Build request.
 	j := jobRequest{Type: "archive-retrieval", ArchiveId: archive, Description: description, SNSTopic: topic}
 	body, _ := json.Marshal(j)
 
-	request, err := http.NewRequest("POST", c.vault(vault)+"/jobs", nil)
+	r, err := c.newRequest(ctx, "InitiateRetrievalJob", "POST", c.vault(vault)+"/jobs")
 	if err != nil {
 		return "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, aws.MemoryPayload(body))
+	r.Payload = aws.MemoryPayload(body)
+	r.Idempotent = false
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusAccepted)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusAccepted {
-		return "", aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
-	return response.Header.Get("x-amz-job-id"), nil
+	return r.HTTPResponse.Header.Get("x-amz-job-id"), nil
 }
 
 // Initiate an vault inventory job with the vault name. You can also provide
@@ -155,33 +154,32 @@ func (c *Connection) InitiateRetrievalJob(vault, archive, topic, description str
 //
 // Returns the job ID or the first error encountered.
 func (c *Connection) InitiateInventoryJob(vault, topic, description string) (string, error) {
+	return c.InitiateInventoryJobContext(context.Background(), vault, topic, description)
+}
+
+// InitiateInventoryJobContext is like InitiateInventoryJob but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) InitiateInventoryJobContext(ctx context.Context, vault, topic, description string) (string, error) {
 	// Build request.
 	j := jobRequest{Type: "inventory-retrieval", Description: description, SNSTopic: topic}
 	body, _ := json.Marshal(j)
 
-	request, err := http.NewRequest("POST", c.vault(vault)+"/jobs", nil)
+	r, err := c.newRequest(ctx, "InitiateInventoryJob", "POST", c.vault(vault)+"/jobs")
 	if err != nil {
 		return "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, aws.MemoryPayload(body))
+	r.Payload = aws.MemoryPayload(body)
+	r.Idempotent = false
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusAccepted)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusAccepted {
-		return "", aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
-	return response.Header.Get("x-amz-job-id"), nil
+	return r.HTTPResponse.Header.Get("x-amz-job-id"), nil
 }
 
 // This operation returns information about a job you previously initiated,
@@ -189,38 +187,28 @@ func (c *Connection) InitiateInventoryJob(vault, topic, description string) (str
 //
 // Returns the job and the first error, if any, encountered.
 func (c *Connection) DescribeJob(vault, jobId string) (*Job, error) {
+	return c.DescribeJobContext(context.Background(), vault, jobId)
+}
+
+// DescribeJobContext is like DescribeJob but uses ctx to cancel the request,
+// and any retries or waits for the rate limiter.
+func (c *Connection) DescribeJobContext(ctx context.Context, vault, jobId string) (*Job, error) {
 	// Build request.
-	request, err := http.NewRequest("GET", c.vault(vault)+"/jobs/"+jobId, nil)
+	r, err := c.newRequest(ctx, "DescribeJob", "GET", c.vault(vault)+"/jobs/"+jobId)
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, nil)
+	var j job
+	r.output = &j
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, aws.ParseError(response)
-	}
 
 	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var j job
-	err = json.Unmarshal(body, &j)
-	if err != nil {
-		return nil, err
-	}
-
 	var result Job
 	if j.ArchiveId != nil {
 		result.ArchiveId = *j.ArchiveId
@@ -287,32 +275,31 @@ func (c *Connection) DescribeJob(vault, jobId string) (*Job, error) {
 // are not interested in its contents, just do `ioutil.Copy(ioutil.Discard, response)`,
 // where `response` is the `io.ReadCloser` retrieved from this function.
 func (c *Connection) GetRetrievalJob(vault, job string, start, end int64) (io.ReadCloser, string, error) {
+	return c.GetRetrievalJobContext(context.Background(), vault, job, start, end)
+}
+
+// GetRetrievalJobContext is like GetRetrievalJob but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) GetRetrievalJobContext(ctx context.Context, vault, job string, start, end int64) (io.ReadCloser, string, error) {
 	// Build request.
-	request, err := http.NewRequest("GET", c.vault(vault)+"/jobs/"+job+"/output", nil)
+	r, err := c.newRequest(ctx, "GetRetrievalJob", "GET", c.vault(vault)+"/jobs/"+job+"/output")
 	if err != nil {
 		return nil, "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 	if end > 0 {
-		request.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		r.HTTPRequest.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 	}
 
-	c.Signature.Sign(request, nil)
+	r.keepBody = true
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK, http.StatusPartialContent)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent {
-		err := aws.ParseError(response)
-		response.Body.Close()
-		return nil, "", err
-	}
-
 	// Parse success response.
-	return response.Body, response.Header.Get("x-amz-sha256-tree-hash"), nil
+	return r.HTTPResponse.Body, r.HTTPResponse.Header.Get("x-amz-sha256-tree-hash"), nil
 }
 
 // Amazon Glacier updates a vault inventory approximately once a day, starting
@@ -327,28 +314,14 @@ func (c *Connection) GetRetrievalJob(vault, job string, start, end int64) (io.Re
 // you might find the vault inventory useful to reconcile information, as
 // needed, in your database with the actual vault inventory.
 func (c *Connection) GetInventoryJob(vault, job string) (*Inventory, error) {
-	// Build request.
-	request, err := http.NewRequest("GET", c.vault(vault)+"/jobs/"+job+"/output", nil)
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
-
-	// Perform request.
-	response, err := c.client().Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, aws.ParseError(response)
-	}
+	return c.GetInventoryJobContext(context.Background(), vault, job)
+}
 
-	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
+// GetInventoryJobContext is like GetInventoryJob but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) GetInventoryJobContext(ctx context.Context, vault, job string) (*Inventory, error) {
+	// Build request.
+	r, err := c.newRequest(ctx, "GetInventoryJob", "GET", c.vault(vault)+"/jobs/"+job+"/output")
 	if err != nil {
 		return nil, err
 	}
@@ -364,11 +337,15 @@ func (c *Connection) GetInventoryJob(vault, job string) (*Inventory, error) {
 			SHA256TreeHash     string
 		}
 	}
-	err = json.Unmarshal(body, &i)
+	r.output = &i
+
+	// Perform request.
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
 
+	// Parse success response.
 	var result Inventory
 	result.VaultARN = i.VaultARN
 	result.InventoryDate, err = time.Parse(time.RFC3339, i.InventoryDate)
@@ -422,6 +399,12 @@ func (c *Connection) GetInventoryJob(vault, job string) (*Inventory, error) {
 // network error. In this scenario, you can retry and download the archive while
 // the job exists.
 func (c *Connection) ListJobs(vault, completed, statusCode, marker string, limit int) ([]Job, string, error) {
+	return c.ListJobsContext(context.Background(), vault, completed, statusCode, marker, limit)
+}
+
+// ListJobsContext is like ListJobs but uses ctx to cancel the request, and any
+// retries or waits for the rate limiter.
+func (c *Connection) ListJobsContext(ctx context.Context, vault, completed, statusCode, marker string, limit int) ([]Job, string, error) {
 	// Build request.
 	parameters := parameters{}
 	if completed != "" {
@@ -441,37 +424,21 @@ func (c *Connection) ListJobs(vault, completed, statusCode, marker string, limit
 		parameters.add("statuscode", statusCode)
 	}
 
-	request, err := http.NewRequest("GET", c.vault(vault)+"/jobs"+parameters.encode(), nil)
+	r, err := c.newRequest(ctx, "ListJobs", "GET", c.vault(vault)+"/jobs"+parameters.encode())
 	if err != nil {
 		return nil, "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, nil)
+	var jl jobList
+	r.output = &jl
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, "", aws.ParseError(response)
-	}
 
 	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, "", err
-	}
-
-	var jl jobList
-	err = json.Unmarshal(body, &jl)
-	if err != nil {
-		return nil, "", err
-	}
-
 	jobs := make([]Job, len(jl.JobList))
 	for i, v := range jl.JobList {
 		jobs[i].Action = v.Action
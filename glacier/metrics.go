@@ -0,0 +1,193 @@
+package glacier
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OperationMetrics are the measurements of an operation made by a Connection.
+type OperationMetrics struct {
+	Operation string // the Connection method, such as "UploadArchive"
+	Err       error  // nil if the operation succeeded
+
+	// Latency is how long the operation took until its response was decoded,
+	// including any retries and waits for the rate limiter. The body returned
+	// by GetRetrievalJob is read after.
+	Latency time.Duration
+
+	Attempts  int // the number of times the request was sent
+	Throttles int // the number of attempts that were throttled
+
+	// The bytes sent in the final attempt's body and received in its
+	// response's body, as given by their Content-Length, zero if unknown.
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// MetricsSink receives the measurements of a Connection's operations. It must
+// be safe for concurrent use if the Connection is used concurrently.
+type MetricsSink interface {
+	RecordOperation(m *OperationMetrics)
+}
+
+// record records the measurements of the operation to the connection's sink.
+func (c *Connection) record(r *Request, latency time.Duration, err error) {
+	m := &OperationMetrics{
+		Operation: r.Operation,
+		Err:       err,
+		Latency:   latency,
+		Attempts:  r.Attempt,
+		Throttles: r.throttles,
+	}
+	if r.HTTPRequest.ContentLength > 0 {
+		m.BytesSent = r.HTTPRequest.ContentLength
+	}
+	if r.HTTPResponse != nil && r.HTTPResponse.ContentLength > 0 {
+		m.BytesReceived = r.HTTPResponse.ContentLength
+	}
+	c.Metrics.RecordOperation(m)
+}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histogram buckets of a MemoryMetrics without its own. They range from the
+// time of a small request to that of uploading a large part on a slow link.
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// MemoryMetrics is a MetricsSink that keeps counts and totals of each
+// operation in memory, and a histogram of their latencies. It is an
+// http.Handler that serves them in the Prometheus text exposition format.
+//
+// The zero value is ready to use. A MemoryMetrics is safe for concurrent use,
+// and may be shared by several Connections.
+type MemoryMetrics struct {
+	// Buckets optionally specifies the upper bounds, in seconds and in
+	// increasing order, of the latency histogram buckets. If nil,
+	// DefaultLatencyBuckets is used. It must not be modified once operations
+	// are recorded.
+	Buckets []float64
+
+	mu         sync.Mutex
+	operations map[string]*operationTotals
+}
+
+// operationTotals are the totals of an operation's measurements.
+type operationTotals struct {
+	succeeded, failed int64
+	latency           float64 // seconds
+	buckets           []int64 // count of latencies in each bucket, not cumulative
+	bytesSent         int64
+	bytesReceived     int64
+	retries           int64
+	throttles         int64
+}
+
+func (m *MemoryMetrics) buckets() []float64 {
+	if m.Buckets == nil {
+		return DefaultLatencyBuckets
+	}
+	return m.Buckets
+}
+
+// RecordOperation adds the operation's measurements to the totals.
+func (m *MemoryMetrics) RecordOperation(o *OperationMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.operations == nil {
+		m.operations = make(map[string]*operationTotals)
+	}
+	t := m.operations[o.Operation]
+	if t == nil {
+		t = &operationTotals{buckets: make([]int64, len(m.buckets()))}
+		m.operations[o.Operation] = t
+	}
+
+	if o.Err == nil {
+		t.succeeded++
+	} else {
+		t.failed++
+	}
+	latency := o.Latency.Seconds()
+	t.latency += latency
+	if i := sort.SearchFloat64s(m.buckets(), latency); i < len(t.buckets) {
+		t.buckets[i]++
+	}
+	t.bytesSent += o.BytesSent
+	t.bytesReceived += o.BytesReceived
+	if o.Attempts > 1 {
+		t.retries += int64(o.Attempts - 1)
+	}
+	t.throttles += int64(o.Throttles)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *MemoryMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *MemoryMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.operations))
+	for name := range m.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	counter := func(metric, help string, value func(t *operationTotals) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{operation=%s} %d\n", metric, labelValue(name), value(m.operations[name]))
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP glacier_operations_total Glacier operations by result.\n")
+	fmt.Fprintf(&b, "# TYPE glacier_operations_total counter\n")
+	for _, name := range names {
+		t := m.operations[name]
+		fmt.Fprintf(&b, "glacier_operations_total{operation=%s,result=\"success\"} %d\n", labelValue(name), t.succeeded)
+		fmt.Fprintf(&b, "glacier_operations_total{operation=%s,result=\"error\"} %d\n", labelValue(name), t.failed)
+	}
+
+	fmt.Fprintf(&b, "# HELP glacier_operation_duration_seconds Latency of Glacier operations, including retries.\n")
+	fmt.Fprintf(&b, "# TYPE glacier_operation_duration_seconds histogram\n")
+	for _, name := range names {
+		t := m.operations[name]
+		var cumulative int64
+		for i, le := range m.buckets() {
+			cumulative += t.buckets[i]
+			fmt.Fprintf(&b, "glacier_operation_duration_seconds_bucket{operation=%s,le=\"%g\"} %d\n",
+				labelValue(name), le, cumulative)
+		}
+		count := t.succeeded + t.failed
+		fmt.Fprintf(&b, "glacier_operation_duration_seconds_bucket{operation=%s,le=\"+Inf\"} %d\n", labelValue(name), count)
+		fmt.Fprintf(&b, "glacier_operation_duration_seconds_sum{operation=%s} %g\n", labelValue(name), t.latency)
+		fmt.Fprintf(&b, "glacier_operation_duration_seconds_count{operation=%s} %d\n", labelValue(name), count)
+	}
+
+	counter("glacier_bytes_sent_total", "Bytes sent in the bodies of Glacier requests.",
+		func(t *operationTotals) int64 { return t.bytesSent })
+	counter("glacier_bytes_received_total", "Bytes received in the bodies of Glacier responses.",
+		func(t *operationTotals) int64 { return t.bytesReceived })
+	counter("glacier_retries_total", "Glacier requests sent again after failing.",
+		func(t *operationTotals) int64 { return t.retries })
+	counter("glacier_throttles_total", "Glacier requests that were throttled.",
+		func(t *operationTotals) int64 { return t.throttles })
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labelValue returns the quoted and escaped Prometheus label value.
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
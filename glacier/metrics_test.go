@@ -0,0 +1,119 @@
+package glacier
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+type metricsFunc func(m *OperationMetrics)
+
+func (f metricsFunc) RecordOperation(m *OperationMetrics) {
+	f(m)
+}
+
+func TestMetrics(t *testing.T) {
+	var attempts int
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case r.Method == "GET":
+			io.WriteString(w, "archive contents")
+		case attempts == 1:
+			writeError(w, http.StatusBadRequest, "ThrottlingException")
+		case strings.HasSuffix(r.URL.Path, "/missing"):
+			writeError(w, http.StatusNotFound, "ResourceNotFoundException")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	c.Retry = &Retry{MaxAttempts: 3}
+	var recorded []OperationMetrics
+	memory := &MemoryMetrics{Buckets: []float64{1, 10}}
+	c.Metrics = metricsFunc(func(m *OperationMetrics) {
+		recorded = append(recorded, *m)
+		memory.RecordOperation(m)
+	})
+
+	part := bytes.Repeat([]byte("part"), 256)
+	if err := c.UploadMultipart("vault", "upload", 0, bytes.NewReader(part)); err != nil {
+		t.Fatal(err)
+	}
+	body, _, err := c.GetRetrievalJob("vault", "job", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(body)
+	body.Close()
+	if err := c.AbortMultipart("vault", "missing"); !aws.IsNotFound(err) {
+		t.Fatal("expected not found, got", err)
+	}
+
+	if len(recorded) != 3 {
+		t.Fatal(len(recorded), "operations recorded, expected 3")
+	}
+	upload, retrieval, abort := recorded[0], recorded[1], recorded[2]
+	if upload.Operation != "UploadMultipart" || upload.Err != nil || upload.Attempts != 2 || upload.Throttles != 1 ||
+		upload.BytesSent != int64(len(part)) || upload.Latency <= 0 {
+		t.Errorf("unexpected upload metrics %+v", upload)
+	}
+	if retrieval.Operation != "GetRetrievalJob" || retrieval.Attempts != 1 ||
+		retrieval.BytesReceived != int64(len("archive contents")) {
+		t.Errorf("unexpected retrieval metrics %+v", retrieval)
+	}
+	if abort.Operation != "AbortMultipart" || !errors.Is(abort.Err, aws.ErrResourceNotFound) {
+		t.Errorf("unexpected abort metrics %+v", abort)
+	}
+
+	recorder := httptest.NewRecorder()
+	memory.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Error("content type", ct)
+	}
+	text := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE glacier_operations_total counter",
+		`glacier_operations_total{operation="UploadMultipart",result="success"} 1`,
+		`glacier_operations_total{operation="AbortMultipart",result="error"} 1`,
+		"# TYPE glacier_operation_duration_seconds histogram",
+		`glacier_operation_duration_seconds_bucket{operation="UploadMultipart",le="1"} 1`,
+		`glacier_operation_duration_seconds_bucket{operation="UploadMultipart",le="10"} 1`,
+		`glacier_operation_duration_seconds_bucket{operation="UploadMultipart",le="+Inf"} 1`,
+		`glacier_operation_duration_seconds_count{operation="GetRetrievalJob"} 1`,
+		`glacier_bytes_sent_total{operation="UploadMultipart"} 1024`,
+		`glacier_bytes_received_total{operation="GetRetrievalJob"} 16`,
+		`glacier_retries_total{operation="UploadMultipart"} 1`,
+		`glacier_throttles_total{operation="UploadMultipart"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestMemoryMetricsBuckets(t *testing.T) {
+	m := &MemoryMetrics{Buckets: []float64{0.1, 1}}
+	for _, latency := range []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, time.Second / 2, time.Minute} {
+		m.RecordOperation(&OperationMetrics{Operation: `Odd "name"`, Latency: latency})
+	}
+	var b strings.Builder
+	m.WriteTo(&b)
+	for _, line := range []string{
+		`glacier_operation_duration_seconds_bucket{operation="Odd \"name\"",le="0.1"} 2`,
+		`glacier_operation_duration_seconds_bucket{operation="Odd \"name\"",le="1"} 3`,
+		`glacier_operation_duration_seconds_bucket{operation="Odd \"name\"",le="+Inf"} 4`,
+		`glacier_operation_duration_seconds_sum{operation="Odd \"name\""} 60.65`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -1,16 +1,13 @@
 package glacier
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
-
-	"github.com/rdwilliamson/aws"
 )
 
 // Multipart contains all relevant data for a multipart upload.
@@ -68,37 +65,35 @@ type MultipartParts struct {
 // multipart upload because Amazon Glacier does not require you to specify the
 // overall archive size.
 func (c *Connection) InitiateMultipart(vault string, size int64, description string) (string, error) {
+	return c.InitiateMultipartContext(context.Background(), vault, size, description)
+}
+
+// InitiateMultipartContext is like InitiateMultipart but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) InitiateMultipartContext(ctx context.Context, vault string, size int64, description string) (string, error) {
 	// Build request.
-	request, err := http.NewRequest("POST", c.vault(vault)+"/multipart-uploads", nil)
+	r, err := c.newRequest(ctx, "InitiateMultipart", "POST", c.vault(vault)+"/multipart-uploads")
 	if err != nil {
 		return "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
 	// TODO check that size is valid
-	request.Header.Add("x-amz-part-size", fmt.Sprint(size))
+	r.HTTPRequest.Header.Add("x-amz-part-size", fmt.Sprint(size))
 
 	if description != "" {
-		request.Header.Add("x-amz-archive-description", description)
+		r.HTTPRequest.Header.Add("x-amz-archive-description", description)
 	}
 
-	c.Signature.Sign(request, nil)
+	r.Idempotent = false
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusCreated)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusCreated {
-		return "", aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
-	return response.Header.Get("x-amz-multipart-upload-id"), nil
+	return r.HTTPResponse.Header.Get("x-amz-multipart-upload-id"), nil
 }
 
 // This multipart upload operation uploads a part of an archive.You can upload
@@ -131,17 +126,23 @@ func (c *Connection) InitiateMultipart(vault string, size int64, description str
 // data included in the most recent request overwrites the previously uploaded
 // data.
 func (c *Connection) UploadMultipart(vault, uploadId string, start int64, body io.ReadSeeker) error {
+	return c.UploadMultipartContext(context.Background(), vault, uploadId, start, body)
+}
+
+// UploadMultipartContext is like UploadMultipart but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter. Canceling the context
+// also stops reading the part to hash it.
+func (c *Connection) UploadMultipartContext(ctx context.Context, vault, uploadId string, start int64, body io.ReadSeeker) error {
 	// TODO check that data size and start location make sense
 
 	// Build request.
-	request, err := http.NewRequest("PUT", c.vault(vault)+"/multipart-uploads/"+uploadId, body)
+	r, err := c.newRequest(ctx, "UploadMultipart", "PUT", c.vault(vault)+"/multipart-uploads/"+uploadId)
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
 	th := NewTreeHash()
-	n, err := io.Copy(th, body)
+	n, err := io.Copy(th, contextReader{ctx, body})
 	if err != nil {
 		return err
 	}
@@ -154,25 +155,18 @@ func (c *Connection) UploadMultipart(vault, uploadId string, start int64, body i
 
 	hash := th.Hash()
 
-	request.Header.Add("x-amz-content-sha256", toHex(hash))
-	request.Header.Add("x-amz-sha256-tree-hash", toHex(th.TreeHash()))
-	request.Header.Add("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, start+n-1))
-	request.ContentLength = n
+	r.HTTPRequest.Header.Add("x-amz-content-sha256", toHex(hash))
+	r.HTTPRequest.Header.Add("x-amz-sha256-tree-hash", toHex(th.TreeHash()))
+	r.HTTPRequest.Header.Add("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, start+n-1))
+	r.HTTPRequest.ContentLength = n
 
-	c.Signature.Sign(request, aws.HashedPayload(hash))
+	r.Payload = &rewindPayload{body, hash}
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -214,33 +208,29 @@ func (c *Connection) UploadMultipart(vault, uploadId string, start int64, body i
 //
 // Note: treeHash must be a hex-encoded string.
 func (c *Connection) CompleteMultipart(vault, uploadId, treeHash string, size int64) (string, error) {
+	return c.CompleteMultipartContext(context.Background(), vault, uploadId, treeHash, size)
+}
+
+// CompleteMultipartContext is like CompleteMultipart but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) CompleteMultipartContext(ctx context.Context, vault, uploadId, treeHash string, size int64) (string, error) {
 	// Build request.
-	request, err := http.NewRequest("POST", c.vault(vault)+"/multipart-uploads/"+uploadId, nil)
+	r, err := c.newRequest(ctx, "CompleteMultipart", "POST", c.vault(vault)+"/multipart-uploads/"+uploadId)
 	if err != nil {
 		return "", err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	request.Header.Add("x-amz-sha256-tree-hash", treeHash)
-	request.Header.Add("x-amz-archive-size", fmt.Sprint(size))
 
-	c.Signature.Sign(request, nil)
+	r.HTTPRequest.Header.Add("x-amz-sha256-tree-hash", treeHash)
+	r.HTTPRequest.Header.Add("x-amz-archive-size", fmt.Sprint(size))
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusCreated)
 	if err != nil {
 		return "", err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusCreated {
-		return "", aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
-	return response.Header.Get("x-amz-archive-id"), nil
+	return r.HTTPResponse.Header.Get("x-amz-archive-id"), nil
 }
 
 // This multipart upload operation aborts a multipart upload identified by the upload ID.
@@ -252,27 +242,23 @@ func (c *Connection) CompleteMultipart(vault, uploadId, treeHash string, size in
 //
 // This operation is idempotent.
 func (c *Connection) AbortMultipart(vault, uploadId string) error {
+	return c.AbortMultipartContext(context.Background(), vault, uploadId)
+}
+
+// AbortMultipartContext is like AbortMultipart but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) AbortMultipartContext(ctx context.Context, vault, uploadId string) error {
 	// Build request.
-	request, err := http.NewRequest("DELETE", c.vault(vault)+"/multipart-uploads/"+uploadId, nil)
+	r, err := c.newRequest(ctx, "AbortMultipart", "DELETE", c.vault(vault)+"/multipart-uploads/"+uploadId)
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -298,6 +284,12 @@ func (c *Connection) AbortMultipart(vault, uploadId string) error {
 // You can also limit the number of parts returned in the response by specifying
 // the limit parameter in the request.
 func (c *Connection) ListMultipartParts(vault, uploadId, marker string, limit int) (*MultipartParts, error) {
+	return c.ListMultipartPartsContext(context.Background(), vault, uploadId, marker, limit)
+}
+
+// ListMultipartPartsContext is like ListMultipartParts but uses ctx to cancel
+// the request, and any retries or waits for the rate limiter.
+func (c *Connection) ListMultipartPartsContext(ctx context.Context, vault, uploadId, marker string, limit int) (*MultipartParts, error) {
 	// Build request.
 	parameters := parameters{}
 	if limit > 0 {
@@ -308,27 +300,7 @@ func (c *Connection) ListMultipartParts(vault, uploadId, marker string, limit in
 		parameters.add("marker", marker)
 	}
 
-	request, err := http.NewRequest("GET", c.vault(vault)+"/multipart-uploads/"+uploadId+parameters.encode(), nil)
-	if err != nil {
-		return nil, err
-	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
-
-	// Perform request.
-	response, err := c.client().Do(request)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, aws.ParseError(response)
-	}
-
-	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
+	r, err := c.newRequest(ctx, "ListMultipartParts", "GET", c.vault(vault)+"/multipart-uploads/"+uploadId+parameters.encode())
 	if err != nil {
 		return nil, err
 	}
@@ -342,11 +314,15 @@ func (c *Connection) ListMultipartParts(vault, uploadId, marker string, limit in
 		Parts              []MultipartPart
 		VaultARN           string
 	}
-	err = json.Unmarshal(body, &list)
+	r.output = &list
+
+	// Perform request.
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
 
+	// Parse success response.
 	var result MultipartParts
 	result.ArchiveDescription = list.ArchiveDescription
 	result.CreationDate, err = time.Parse(time.RFC3339, list.CreationDate)
@@ -385,6 +361,12 @@ func (c *Connection) ListMultipartParts(vault, uploadId, marker string, limit in
 // List Parts operation returns parts of a specific multipart upload identified
 // by an Upload ID.
 func (c *Connection) ListMultipartUploads(vault, marker string, limit int) ([]Multipart, string, error) {
+	return c.ListMultipartUploadsContext(context.Background(), vault, marker, limit)
+}
+
+// ListMultipartUploadsContext is like ListMultipartUploads but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) ListMultipartUploadsContext(ctx context.Context, vault, marker string, limit int) ([]Multipart, string, error) {
 	// Build request.
 	query := url.Values{}
 	if limit > 0 {
@@ -395,27 +377,7 @@ func (c *Connection) ListMultipartUploads(vault, marker string, limit int) ([]Mu
 		query.Add("marker", marker)
 	}
 
-	request, err := http.NewRequest("GET", c.vault(vault)+"/multipart-uploads"+query.Encode(), nil)
-	if err != nil {
-		return nil, "", err
-	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
-
-	// Perform request.
-	response, err := c.client().Do(request)
-	if err != nil {
-		return nil, "", err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, "", aws.ParseError(response)
-	}
-
-	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
+	r, err := c.newRequest(ctx, "ListMultipartUploads", "GET", c.vault(vault)+"/multipart-uploads"+query.Encode())
 	if err != nil {
 		return nil, "", err
 	}
@@ -430,11 +392,15 @@ func (c *Connection) ListMultipartUploads(vault, marker string, limit int) ([]Mu
 			VaultARN           string
 		}
 	}
-	err = json.Unmarshal(body, &list)
+	r.output = &list
+
+	// Perform request.
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Parse success response.
 	parts := make([]Multipart, len(list.UploadsList))
 	for i, v := range list.UploadsList {
 		if v.ArchiveDescription != nil {
@@ -461,10 +427,17 @@ func (c *Connection) ListMultipartUploads(vault, marker string, limit int) ([]Mu
 // and returns a hex-formatted treehash of the entire archive,
 // for use in sending along with a CompleteMultipart request.
 func (c *Connection) TreeHashFromMultipartUpload(vault, uploadID string) (string, error) {
+	return c.TreeHashFromMultipartUploadContext(context.Background(), vault, uploadID)
+}
+
+// TreeHashFromMultipartUploadContext is like TreeHashFromMultipartUpload but
+// uses ctx to cancel the request, and any retries or waits for the rate
+// limiter.
+func (c *Connection) TreeHashFromMultipartUploadContext(ctx context.Context, vault, uploadID string) (string, error) {
 	marker := ""
 	m := MultiTreeHasher{}
 	for {
-		parts, err := c.ListMultipartParts(vault, uploadID, marker, 0)
+		parts, err := c.ListMultipartPartsContext(ctx, vault, uploadID, marker, 0)
 		if err != nil {
 			return "", err
 		}
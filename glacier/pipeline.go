@@ -0,0 +1,279 @@
+package glacier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// Request is a Glacier operation's request as it goes through a Connection's
+// pipeline.
+type Request struct {
+	// Operation is the name of the Connection method making the request, such
+	// as "UploadArchive".
+	Operation string
+
+	// Vault is the name of the vault the operation is on, empty for those on
+	// no particular vault.
+	Vault string
+
+	HTTPRequest *http.Request
+
+	// Payload is the body the request is signed and sent with, see
+	// aws.Signature.Sign. It is set again by each attempt so it must rewind
+	// the body. If nil, the request has no body.
+	Payload aws.Payload
+
+	// Idempotent is whether the request may be retried.
+	Idempotent bool
+
+	// Attempt is the number of the attempt being made, starting at 1.
+	Attempt int
+
+	// HTTPResponse is the response to the attempt. If it is an error response
+	// its body has already been read and closed.
+	HTTPResponse *http.Response
+
+	status        []int       // status codes of a successful response
+	output        interface{} // decoded from a successful response's body
+	keepBody      bool        // the caller closes a successful response's body
+	clockAdjusted bool        // the attempt's error corrected the clock
+	throttles     int         // attempts that were throttled
+}
+
+// Handler handles a request at a stage of the pipeline.
+type Handler func(r *Request) error
+
+// Middleware wraps the handler of a stage, and the stages after it, with
+// another. It may change the request before calling next, inspect the
+// response or error after, or return without calling next to skip the rest of
+// the pipeline, such as to inject a fault or replay a recorded response.
+type Middleware func(next Handler) Handler
+
+// Stage is a stage of the pipeline every Glacier operation goes through. The
+// stages run in order, each calling the next, so a stage sees the results of
+// those after it.
+type Stage int
+
+const (
+	// StageBuild adds the headers common to every operation. It runs once per
+	// operation.
+	StageBuild Stage = iota
+
+	// StageUnmarshal checks the status code of the response to the final
+	// attempt and decodes its body. It runs once per operation.
+	StageUnmarshal
+
+	// StageRetry waits for the connection's RateLimiter before each attempt,
+	// and retries failed attempts as described by the connection's Retry.
+	StageRetry
+
+	// StageSign signs each attempt for the time it is sent.
+	StageSign
+
+	// StageLog logs each attempt to the connection's Logger, if it has one,
+	// once it has a response or error. Its middleware see each signed attempt,
+	// and its response or error.
+	StageLog
+
+	// StageSend sends each attempt. A response with a status code of 400 or
+	// more is returned as the error parsed from it.
+	StageSend
+
+	numStages
+)
+
+// Use adds middleware to the stage of the connection's pipeline. The stage's
+// middleware run in the order they were added, before the stage itself. It
+// must not be called while the connection is making requests.
+func (c *Connection) Use(stage Stage, middleware ...Middleware) {
+	c.middleware[stage] = append(c.middleware[stage], middleware...)
+}
+
+// handler returns the handler of the stage, which calls those after it,
+// wrapped in the stage's middleware.
+func (c *Connection) handler(stage Stage) Handler {
+	var h Handler
+	if stage == StageSend {
+		h = c.sendStage
+	} else {
+		next := c.handler(stage + 1)
+		run := [...]func(*Request, Handler) error{
+			StageBuild:     c.buildStage,
+			StageUnmarshal: c.unmarshalStage,
+			StageRetry:     c.retryStage,
+			StageSign:      c.signStage,
+			StageLog:       c.logStage,
+		}[stage]
+		h = func(r *Request) error {
+			return run(r, next)
+		}
+	}
+	for i := len(c.middleware[stage]) - 1; i >= 0; i-- {
+		h = c.middleware[stage][i](h)
+	}
+	return h
+}
+
+// newRequest returns an idempotent request without a body for the operation.
+func (c *Connection) newRequest(ctx context.Context, operation, method, url string) (*Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &Request{Operation: operation, HTTPRequest: request, Idempotent: true}
+	if vault := strings.TrimPrefix(url, c.account()+"/vaults/"); vault != url {
+		r.Vault = vault[:strings.IndexAny(vault+"/", "/?")]
+	}
+	return r, nil
+}
+
+// run sends the request through the pipeline. A response with a status code
+// other than those given is returned as the error parsed from it.
+func (c *Connection) run(r *Request, status ...int) error {
+	r.status = status
+	start := time.Now()
+	err := c.handler(StageBuild)(r)
+	if c.Metrics != nil {
+		c.record(r, time.Since(start), err)
+	}
+	return err
+}
+
+func (c *Connection) buildStage(r *Request, next Handler) error {
+	r.HTTPRequest.Header.Set("x-amz-glacier-version", "2012-06-01")
+	return next(r)
+}
+
+func (c *Connection) unmarshalStage(r *Request, next Handler) error {
+	err := next(r)
+	if err != nil {
+		return err
+	}
+	response := r.HTTPResponse
+
+	success := false
+	for _, status := range r.status {
+		success = success || response.StatusCode == status
+	}
+	if !success {
+		defer response.Body.Close()
+		return c.parseError(response)
+	}
+	if r.keepBody {
+		return nil
+	}
+	defer response.Body.Close()
+
+	if r.output == nil {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err == nil {
+		err = json.Unmarshal(body, r.output)
+	}
+	if err != nil {
+		if id := requestID(response); id != "" {
+			return fmt.Errorf("glacier: %s response (request ID %s): %w", r.Operation, id, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Connection) signStage(r *Request, next Handler) error {
+	r.HTTPRequest.Header.Del("X-Amz-Date")
+	r.HTTPRequest.Header.Del("Authorization")
+	err := c.Signature.Sign(r.HTTPRequest, r.Payload)
+	if err != nil {
+		return err
+	}
+	return next(r)
+}
+
+// redactedHeaders are the headers whose values aren't logged.
+var redactedHeaders = map[string]bool{
+	"Authorization":        true,
+	"X-Amz-Security-Token": true,
+}
+
+// logStage logs the attempt with its operation, vault, status code, request
+// ID, latency, and the bytes sent and received, at the info level if it
+// succeeded and the warning level if not. At the debug level the request's
+// headers are logged too, except for the credentials in redactedHeaders.
+func (c *Connection) logStage(r *Request, next Handler) error {
+	if c.Logger == nil {
+		return next(r)
+	}
+	ctx := r.HTTPRequest.Context()
+	start := time.Now()
+	err := next(r)
+
+	attrs := []slog.Attr{
+		slog.String("operation", r.Operation),
+		slog.String("vault", r.Vault),
+		slog.Int("attempt", r.Attempt),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int64("bytes_sent", r.HTTPRequest.ContentLength),
+	}
+	if r.HTTPResponse != nil {
+		attrs = append(attrs,
+			slog.Int("status", r.HTTPResponse.StatusCode),
+			slog.String("request_id", requestID(r.HTTPResponse)),
+			slog.Int64("bytes_received", r.HTTPResponse.ContentLength))
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if c.Logger.Enabled(ctx, slog.LevelDebug) {
+		var names []string
+		for name := range r.HTTPRequest.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var headers []any
+		for _, name := range names {
+			value := strings.Join(r.HTTPRequest.Header[name], ", ")
+			if redactedHeaders[name] {
+				value = "REDACTED"
+			}
+			headers = append(headers, slog.String(name, value))
+		}
+		attrs = append(attrs, slog.Group("headers", headers...))
+	}
+	c.Logger.LogAttrs(ctx, level, "glacier request", attrs...)
+	return err
+}
+
+// requestID returns the ID AWS assigned to the request the response is to.
+func requestID(response *http.Response) string {
+	return response.Header.Get("x-amzn-RequestId")
+}
+
+func (c *Connection) sendStage(r *Request) error {
+	response, err := c.client().Do(r.HTTPRequest)
+	if err != nil {
+		return err
+	}
+	r.HTTPResponse = response
+	if response.StatusCode < 400 {
+		return nil
+	}
+	defer response.Body.Close()
+
+	err = aws.ParseError(response)
+	r.clockAdjusted = c.Signature.AdjustClock(response, err)
+	return err
+}
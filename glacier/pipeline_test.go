@@ -0,0 +1,275 @@
+package glacier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// testBody is a response body every operation can parse.
+const testBody = `{"CreationDate":"2012-03-20T17:03:43Z","InventoryDate":"2012-03-20T17:03:43Z",` +
+	`"Policy":{"Rules":[{"Strategy":"FreeTier"}]}}`
+
+func TestPipelineOperations(t *testing.T) {
+	c := NewConnection("secret", "access", aws.USEast1)
+	stages := map[string][]Stage{}
+	for stage := StageBuild; stage < numStages; stage++ {
+		stage := stage
+		c.Use(stage, func(next Handler) Handler {
+			return func(r *Request) error {
+				stages[r.Operation] = append(stages[r.Operation], stage)
+				return next(r)
+			}
+		})
+	}
+	// Replay a successful response instead of sending the request.
+	c.Use(StageSend, func(next Handler) Handler {
+		return func(r *Request) error {
+			if r.HTTPRequest.Header.Get("Authorization") == "" {
+				t.Error(r.Operation, "not signed")
+			}
+			r.HTTPResponse = &http.Response{
+				StatusCode: r.status[0],
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader(testBody)),
+			}
+			return nil
+		}
+	})
+
+	operations := map[string]func() error{
+		"UploadArchive": func() error {
+			_, err := c.UploadArchive("vault", bytes.NewReader([]byte("archive")), "")
+			return err
+		},
+		"DeleteArchive": func() error { return c.DeleteArchive("vault", "archive") },
+		"InitiateRetrievalJob": func() error {
+			_, err := c.InitiateRetrievalJob("vault", "archive", "", "")
+			return err
+		},
+		"InitiateInventoryJob": func() error {
+			_, err := c.InitiateInventoryJob("vault", "", "")
+			return err
+		},
+		"DescribeJob": func() error {
+			_, err := c.DescribeJob("vault", "job")
+			return err
+		},
+		"GetRetrievalJob": func() error {
+			body, _, err := c.GetRetrievalJob("vault", "job", 0, 0)
+			if err == nil {
+				body.Close()
+			}
+			return err
+		},
+		"GetInventoryJob": func() error {
+			_, err := c.GetInventoryJob("vault", "job")
+			return err
+		},
+		"ListJobs": func() error {
+			_, _, err := c.ListJobs("vault", "", "", "", 0)
+			return err
+		},
+		"InitiateMultipart": func() error {
+			_, err := c.InitiateMultipart("vault", 1<<20, "")
+			return err
+		},
+		"UploadMultipart": func() error {
+			return c.UploadMultipart("vault", "upload", 0, bytes.NewReader([]byte("part")))
+		},
+		"CompleteMultipart": func() error {
+			_, err := c.CompleteMultipart("vault", "upload", "hash", 4)
+			return err
+		},
+		"AbortMultipart": func() error { return c.AbortMultipart("vault", "upload") },
+		"ListMultipartParts": func() error {
+			_, err := c.ListMultipartParts("vault", "upload", "", 0)
+			return err
+		},
+		"ListMultipartUploads": func() error {
+			_, _, err := c.ListMultipartUploads("vault", "", 0)
+			return err
+		},
+		"GetDataRetrievalPolicy": func() error {
+			_, _, err := c.GetDataRetrievalPolicy()
+			return err
+		},
+		"SetRetrievalPolicy": func() error { return c.SetRetrievalPolicy(FreeTier, 0) },
+		"CreateVault":        func() error { return c.CreateVault("vault") },
+		"DeleteVault":        func() error { return c.DeleteVault("vault") },
+		"DescribeVault": func() error {
+			_, err := c.DescribeVault("vault")
+			return err
+		},
+		"ListVaults": func() error {
+			_, _, err := c.ListVaults("", 0)
+			return err
+		},
+		"SetVaultNotifications": func() error {
+			return c.SetVaultNotifications("vault", &Notifications{})
+		},
+		"GetVaultNotifications": func() error {
+			_, err := c.GetVaultNotifications("vault")
+			return err
+		},
+		"DeleteVaultNotifications": func() error { return c.DeleteVaultNotifications("vault") },
+	}
+
+	all := []Stage{StageBuild, StageUnmarshal, StageRetry, StageSign, StageLog, StageSend}
+	for name, operation := range operations {
+		if err := operation(); err != nil {
+			t.Error(name, err)
+		}
+		if !reflect.DeepEqual(stages[name], all) {
+			t.Error(name, "went through stages", stages[name])
+		}
+	}
+
+	// TreeHashFromMultipartUpload lists the parts.
+	delete(stages, "ListMultipartParts")
+	if _, err := c.TreeHashFromMultipartUpload("vault", "upload"); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(stages["ListMultipartParts"], all) {
+		t.Error("TreeHashFromMultipartUpload went through stages", stages["ListMultipartParts"])
+	}
+}
+
+func TestPipelineMiddleware(t *testing.T) {
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "backup/1.0" {
+			t.Error("user agent", r.Header.Get("User-Agent"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c.Retry = &Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	// Headers added while building are signed.
+	c.Use(StageBuild, func(next Handler) Handler {
+		return func(r *Request) error {
+			r.HTTPRequest.Header.Set("User-Agent", "backup/1.0")
+			return next(r)
+		}
+	})
+
+	// Faults injected before sending are retried.
+	var attempts []int
+	c.Use(StageSend, func(next Handler) Handler {
+		return func(r *Request) error {
+			attempts = append(attempts, r.Attempt)
+			if r.Attempt == 1 {
+				return aws.ErrServiceUnavailable
+			}
+			return next(r)
+		}
+	})
+
+	// And the log stage sees every attempt's result.
+	var logged []error
+	c.Use(StageLog, func(next Handler) Handler {
+		return func(r *Request) error {
+			err := next(r)
+			logged = append(logged, err)
+			return err
+		}
+	})
+
+	if err := c.DeleteVault("vault"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(attempts, []int{1, 2}) {
+		t.Error("attempts", attempts)
+	}
+	if len(logged) != 2 || logged[0] != aws.ErrServiceUnavailable || logged[1] != nil {
+		t.Error("logged", logged)
+	}
+
+	// Non-idempotent operations aren't retried.
+	attempts = nil
+	if _, err := c.InitiateMultipart("vault", 1<<20, ""); err != aws.ErrServiceUnavailable {
+		t.Error("expected injected fault, got", err)
+	}
+	if len(attempts) != 1 {
+		t.Error("attempts", attempts)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var attempts int
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("x-amzn-RequestId", "request-"+strconv.Itoa(attempts))
+		if attempts == 1 {
+			writeError(w, http.StatusServiceUnavailable, "ServiceUnavailableException")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c.Retry = &Retry{MaxAttempts: 2}
+	var buf bytes.Buffer
+	c.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c.Signature = aws.NewSessionSignature("secret", "access", "token", aws.USEast1, "glacier")
+	c.Signature.Clock = &testClock{now: time.Now().UTC()}
+
+	if err := c.DeleteArchive("vault", "archive"); err != nil {
+		t.Fatal(err)
+	}
+	type record struct {
+		Level     string
+		Msg       string
+		Operation string
+		Vault     string
+		Attempt   int
+		Status    int
+		RequestID string `json:"request_id"`
+		Error     string
+		Headers   map[string]string
+	}
+	var records []record
+	for decoder := json.NewDecoder(bytes.NewReader(buf.Bytes())); decoder.More(); {
+		var r record
+		if err := decoder.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 {
+		t.Fatal(len(records), "records, expected 2")
+	}
+	for i, record := range records {
+		if record.Msg != "glacier request" || record.Operation != "DeleteArchive" || record.Vault != "vault" ||
+			record.Attempt != i+1 || record.RequestID != "request-"+strconv.Itoa(i+1) {
+			t.Errorf("unexpected record %+v", record)
+		}
+		if record.Headers["Authorization"] != "REDACTED" || record.Headers["X-Amz-Security-Token"] != "REDACTED" ||
+			record.Headers["X-Amz-Glacier-Version"] != "2012-06-01" {
+			t.Error("headers", record.Headers)
+		}
+	}
+	if records[0].Level != "WARN" || records[0].Status != http.StatusServiceUnavailable || records[0].Error == "" ||
+		records[1].Level != "INFO" || records[1].Status != http.StatusNoContent || records[1].Error != "" {
+		t.Errorf("unexpected records %+v", records)
+	}
+	if strings.Contains(buf.String(), "Signature=") {
+		t.Error("signature logged")
+	}
+
+	// Errors returned carry the request ID.
+	attempts = 0
+	c.Retry = &Retry{MaxAttempts: 1}
+	err := c.DeleteArchive("vault", "archive")
+	var e *aws.Error
+	if !errors.As(err, &e) || e.RequestID != "request-1" || !strings.Contains(err.Error(), "request-1") {
+		t.Error("error without request ID", err)
+	}
+}
@@ -2,9 +2,8 @@ package glacier
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"strings"
 
@@ -140,34 +139,28 @@ type dataRetrievalPolicy struct {
 //
 // For more information about data retrieval policies, see DataRetrievalPolicy.
 func (c *Connection) GetDataRetrievalPolicy() (DataRetrievalPolicy, int, error) {
+	return c.GetDataRetrievalPolicyContext(context.Background())
+}
+
+// GetDataRetrievalPolicyContext is like GetDataRetrievalPolicy but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) GetDataRetrievalPolicyContext(ctx context.Context) (DataRetrievalPolicy, int, error) {
 	// Build request.
-	request, err := http.NewRequest("GET", c.policy("data-retrieval"), nil)
+	r, err := c.newRequest(ctx, "GetDataRetrievalPolicy", "GET", c.policy("data-retrieval"))
 	if err != nil {
 		return 0, 0, err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, nil)
+	var policy dataRetrievalPolicy
+	r.output = &policy
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return 0, 0, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return 0, 0, aws.ParseError(response)
-	}
 
 	// Parse success response.
-	var policy dataRetrievalPolicy
-	decoder := json.NewDecoder(response.Body)
-	err = decoder.Decode(&policy)
-	if err != nil {
-		return 0, 0, err
-	}
-
 	var bytesPerHour int
 	if policy.Policy.Rules[0].BytesPerHour != nil {
 		bytesPerHour = *policy.Policy.Rules[0].BytesPerHour
@@ -186,6 +179,12 @@ func (c *Connection) GetDataRetrievalPolicy() (DataRetrievalPolicy, int, error)
 // before the policy was enacted. For more information about data retrieval
 // policies, see DataRetrievalPolicy.
 func (c *Connection) SetRetrievalPolicy(drp DataRetrievalPolicy, bytesPerHour int) error {
+	return c.SetRetrievalPolicyContext(context.Background(), drp, bytesPerHour)
+}
+
+// SetRetrievalPolicyContext is like SetRetrievalPolicy but uses ctx to cancel
+// the request, and any retries or waits for the rate limiter.
+func (c *Connection) SetRetrievalPolicyContext(ctx context.Context, drp DataRetrievalPolicy, bytesPerHour int) error {
 	// Build request.
 	var rules dataRetrievalPolicy
 	rules.Policy.Rules[0].Strategy = drp.String()
@@ -198,26 +197,19 @@ func (c *Connection) SetRetrievalPolicy(drp DataRetrievalPolicy, bytesPerHour in
 	}
 	reader := bytes.NewReader(data)
 
-	request, err := http.NewRequest("PUT", c.policy("data-retrieval"), reader)
+	r, err := c.newRequest(ctx, "SetRetrievalPolicy", "PUT", c.policy("data-retrieval"))
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, aws.ReadSeekerPayload(reader))
+	r.HTTPRequest.ContentLength = int64(len(data))
+	r.Payload = aws.ReadSeekerPayload(reader)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -0,0 +1,129 @@
+package glacier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// RateLimiter limits how fast Connections send requests with a token bucket
+// whose rate adapts to throttling. When AWS responds that a request was
+// throttled the rate is multiplied by Backoff, no lower than MinRate, and it
+// then recovers by Recovery requests per second every second until it is back
+// to MaxRate. Throttling responses to requests sent before the rate was last
+// lowered don't lower it again, so a burst of them counts once.
+//
+// A RateLimiter may be shared by several Connections and is safe for
+// concurrent use, provided its fields are not modified once it is in use.
+type RateLimiter struct {
+	MaxRate  float64 // requests per second
+	MinRate  float64 // requests per second
+	Burst    int     // requests that may be sent at once after being idle
+	Backoff  float64 // factor the rate is multiplied by when throttled
+	Recovery float64 // requests per second regained per second
+
+	// Clock optionally specifies the clock the limiter uses. If nil,
+	// aws.SystemClock is used.
+	Clock aws.Clock
+
+	mu          sync.Mutex
+	floor       float64   // rate when last throttled
+	throttledAt time.Time // zero if never throttled
+	tokens      float64
+	last        time.Time // when tokens was last updated
+}
+
+// NewRateLimiter returns a RateLimiter that allows rate requests per second
+// with bursts of up to burst requests. When throttled it halves its rate, down
+// to a request every ten seconds, and recovers to the full rate within 30
+// seconds.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		MaxRate:  rate,
+		MinRate:  0.1,
+		Burst:    burst,
+		Backoff:  0.5,
+		Recovery: rate / 30,
+	}
+}
+
+func (l *RateLimiter) now() time.Time {
+	if l.Clock == nil {
+		return aws.SystemClock.Now()
+	}
+	return l.Clock.Now()
+}
+
+// Rate returns the number of requests per second currently allowed.
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate(l.now())
+}
+
+// rate returns the rate at the time. The caller must hold l.mu.
+func (l *RateLimiter) rate(now time.Time) float64 {
+	if l.throttledAt.IsZero() {
+		return l.MaxRate
+	}
+	rate := l.floor + l.Recovery*now.Sub(l.throttledAt).Seconds()
+	if rate > l.MaxRate {
+		return l.MaxRate
+	}
+	return rate
+}
+
+// reserve takes a token for a request and returns how long to wait before
+// sending it. Tokens may be taken before they are available, the wait is
+// until then.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	rate := l.rate(now)
+	if l.last.IsZero() {
+		l.tokens = float64(l.Burst)
+	} else if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += rate * elapsed.Seconds()
+		if l.tokens > float64(l.Burst) {
+			l.tokens = float64(l.Burst)
+		}
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / rate * float64(time.Second))
+}
+
+// cancel returns the token of a request that won't be sent, such as because
+// its context was canceled while waiting for it.
+func (l *RateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > float64(l.Burst) {
+		l.tokens = float64(l.Burst)
+	}
+}
+
+// throttled lowers the rate because a request sent at the time was throttled.
+func (l *RateLimiter) throttled(sent time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.throttledAt.IsZero() && !sent.After(l.throttledAt) {
+		return
+	}
+	now := l.now()
+	l.floor = l.rate(now) * l.Backoff
+	if l.floor < l.MinRate {
+		l.floor = l.MinRate
+	}
+	l.throttledAt = now
+}
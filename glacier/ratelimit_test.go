@@ -0,0 +1,138 @@
+package glacier
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clock := &testClock{now: time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)}
+	l := NewRateLimiter(10, 2)
+	l.Clock = clock
+
+	// The burst is allowed at once, then requests wait for tokens.
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if d := l.reserve(); d != want {
+			t.Errorf("request %d waits %v, expected %v", i, d, want)
+		}
+	}
+	clock.Add(time.Second)
+	if d := l.reserve(); d != 0 {
+		t.Error("request after being idle waits", d)
+	}
+
+	expectRate := func(want float64) {
+		t.Helper()
+		if got := l.Rate(); math.Abs(got-want) > 1e-9 {
+			t.Errorf("rate %v, expected %v", got, want)
+		}
+	}
+	expectRate(10)
+
+	// Throttling halves the rate once for the requests sent before it.
+	sent := clock.Now()
+	clock.Add(time.Millisecond)
+	l.throttled(sent)
+	expectRate(5)
+	l.throttled(sent)
+	expectRate(5)
+
+	// And recovers gradually.
+	clock.Add(3 * time.Second)
+	expectRate(6)
+	clock.Add(time.Minute)
+	expectRate(10)
+
+	// But not below the minimum.
+	for i := 0; i < 10; i++ {
+		clock.Add(time.Millisecond)
+		l.throttled(clock.Now())
+	}
+	expectRate(l.MinRate)
+	l.reserve()
+	l.reserve()
+	if d := l.reserve(); d != 10*time.Second {
+		t.Error("request at the minimum rate waits", d)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	c, clock, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c.RateLimiter = NewRateLimiter(1, 1)
+	c.RateLimiter.Clock = clock
+
+	if err := c.DeleteArchive("vault", "archive"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests canceled while waiting for a token give it back. The clock
+	// doesn't move while they wait, as they're canceled before it is due.
+	c.sleep = func(time.Duration) {}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		if err := c.DeleteArchiveContext(ctx, "vault", "archive"); err != context.Canceled {
+			t.Fatal("expected context.Canceled, got", err)
+		}
+	}
+	if d := c.RateLimiter.reserve(); d != time.Second {
+		t.Error("request after canceled requests waits", d)
+	}
+}
+
+func TestRateLimiterThrottling(t *testing.T) {
+	throttle := 0
+	c, clock, sleeps := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if throttle > 0 {
+			throttle--
+			writeError(w, http.StatusBadRequest, "ThrottlingException")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c.Retry = &Retry{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	c.RateLimiter = NewRateLimiter(4, 1)
+	c.RateLimiter.Clock = clock
+
+	// Requests within the rate aren't delayed.
+	for i := 0; i < 3; i++ {
+		if err := c.DeleteArchive("vault", "archive"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Add(250 * time.Millisecond)
+	}
+	for _, d := range *sleeps {
+		if d != 0 {
+			t.Error("requests delayed", *sleeps)
+			break
+		}
+	}
+
+	// Each throttled attempt lowers the rate, and retries wait for it.
+	*sleeps = nil
+	throttle = 2
+	if err := c.DeleteArchive("vault", "archive"); err != nil {
+		t.Fatal(err)
+	}
+	if rate := c.RateLimiter.Rate(); rate > 1.5 {
+		t.Error("rate", rate, "after throttling twice")
+	}
+	var waited time.Duration
+	for _, d := range *sleeps {
+		waited += d
+	}
+	if waited < 500*time.Millisecond {
+		t.Error("retries waited", waited, *sleeps)
+	}
+
+	// The rate recovers as requests succeed.
+	clock.Add(time.Minute)
+	if rate := c.RateLimiter.Rate(); rate != 4 {
+		t.Error("rate", rate, "after recovering")
+	}
+}
@@ -0,0 +1,151 @@
+package glacier
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// Retry describes how a Connection retries requests that failed in a way that
+// may succeed when sent again, such as a 500 or 503 response, throttling, or a
+// connection reset before a response, see aws.IsRetryable. Only idempotent
+// operations are retried, so UploadArchive, InitiateMultipart, and the
+// operations initiating jobs, which would create another archive, upload, or
+// job, are never retried.
+//
+// Before each retry the Connection waits a random duration between zero and
+// the backoff (full jitter), which starts at BaseDelay and doubles with each
+// retry up to MaxDelay.
+type Retry struct {
+	// MaxAttempts is the most times a request is sent, including the first.
+	// Zero or one means requests are not retried.
+	MaxAttempts int
+
+	// MaxElapsed is how long after a request is first sent it may be
+	// retried, a retry that would be sent later is not made. Zero means there
+	// is no limit.
+	MaxElapsed time.Duration
+
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetry is how requests are retried by a Connection without a Retry.
+var DefaultRetry = &Retry{
+	MaxAttempts: 5,
+	MaxElapsed:  5 * time.Minute,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// backoff returns how long to wait before sending the request again after the
+// attempt, a random duration up to the capped exponential backoff.
+func (r *Retry) backoff(attempt int) time.Duration {
+	backoff := r.MaxDelay
+	if attempt < 32 {
+		if d := r.BaseDelay << uint(attempt-1); d > 0 && d < backoff {
+			backoff = d
+		}
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+func (c *Connection) retry() *Retry {
+	if c.Retry == nil {
+		return DefaultRetry
+	}
+	return c.Retry
+}
+
+// now returns the time of the signature's clock.
+func (c *Connection) now() time.Time {
+	if c.Signature.Clock == nil {
+		return aws.SystemClock.Now()
+	}
+	return c.Signature.Clock.Now()
+}
+
+// wait sleeps for the duration, or until ctx is done in which case it returns
+// ctx's error.
+func (c *Connection) wait(ctx context.Context, d time.Duration) error {
+	if c.sleep != nil {
+		c.sleep(d)
+		return ctx.Err()
+	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryStage makes attempts until one succeeds, and if the request is
+// idempotent retries those that may succeed if sent again. Every attempt waits
+// for the connection's RateLimiter, if it has one, and throttling lowers its
+// rate. If the request's context is done while waiting, its error is returned
+// and the limiter's token is returned to it.
+func (c *Connection) retryStage(r *Request, next Handler) error {
+	ctx := r.HTTPRequest.Context()
+	retry := c.retry()
+	start := c.now()
+	for r.Attempt = 1; ; r.Attempt++ {
+		var sent time.Time
+		if c.RateLimiter != nil {
+			if err := c.wait(ctx, c.RateLimiter.reserve()); err != nil {
+				c.RateLimiter.cancel()
+				return err
+			}
+			sent = c.RateLimiter.now()
+		}
+		r.HTTPResponse, r.clockAdjusted = nil, false
+		err := next(r)
+		if err == nil {
+			return nil
+		}
+		if aws.IsThrottle(err) {
+			r.throttles++
+			if c.RateLimiter != nil {
+				c.RateLimiter.throttled(sent)
+			}
+		}
+		again := r.clockAdjusted || aws.IsRetryable(err)
+		if !r.Idempotent || !again || r.Attempt >= retry.MaxAttempts {
+			return err
+		}
+		delay := retry.backoff(r.Attempt)
+		if retry.MaxElapsed > 0 && c.now().Add(delay).Sub(start) > retry.MaxElapsed {
+			return err
+		}
+		if err := c.wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// rewindPayload is the payload of a body whose hash is already known, which is
+// rewound to its start each time the request is signed.
+type rewindPayload struct {
+	body io.ReadSeeker
+	hash []byte
+}
+
+func (p *rewindPayload) Payload() (io.ReadCloser, []byte, error) {
+	_, err := p.body.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ioutil.NopCloser(p.body), p.hash, nil
+}
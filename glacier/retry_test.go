@@ -0,0 +1,273 @@
+package glacier
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func testLookup(accessID string) (string, error) {
+	if accessID != "access" {
+		return "", aws.ErrUnknownAccessKey
+	}
+	return "secret", nil
+}
+
+// testServer starts a stand-in for Glacier that verifies the signature of each
+// request, so every attempt must be signed again, and then calls handler. It
+// returns a connection to the server whose retries advance the connection's
+// clock instead of sleeping, and the durations it slept for.
+func testServer(t *testing.T, handler http.HandlerFunc) (*Connection, *testClock, *[]time.Duration) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := aws.Verify(r, testLookup); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"code":"`+err.(*aws.Error).Code+`","message":"`+err.Error()+`","type":"Client"}`)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &testClock{now: time.Now().UTC()}
+	var sleeps []time.Duration
+	c := NewConnection("secret", "access", aws.USEast1)
+	c.Signature.Clock = clock
+	c.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme = u.Scheme
+		r.URL.Host = u.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	c.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+		clock.Add(d)
+	}
+	return c, clock, &sleeps
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	io.WriteString(w, `{"code":"`+code+`","message":"`+code+`","type":"Server"}`)
+}
+
+func TestRetryUploadMultipart(t *testing.T) {
+	part := bytes.Repeat([]byte("part"), 1024)
+	var attempts int
+	c, _, sleeps := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || !bytes.Equal(body, part) {
+			t.Errorf("attempt %d: body of %d bytes, %v", attempts, len(body), err)
+		}
+		switch attempts {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "<html>Service Unavailable</html>")
+		case 2:
+			writeError(w, http.StatusBadRequest, "ThrottlingException")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	c.Retry = &Retry{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	err := c.UploadMultipart("vault", "upload", 0, bytes.NewReader(part))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Error(attempts, "attempts, expected 3")
+	}
+	if len(*sleeps) != 2 || (*sleeps)[0] > time.Second || (*sleeps)[1] > 2*time.Second {
+		t.Error("unexpected backoff", *sleeps)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var attempts int
+	c, _, sleeps := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		writeError(w, http.StatusInternalServerError, "ServiceUnavailableException")
+	})
+
+	// Attempts.
+	c.Retry = &Retry{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	err := c.DeleteVault("vault")
+	if e, ok := err.(*aws.Error); !ok || e.Code != "ServiceUnavailableException" {
+		t.Error("unexpected error", err)
+	}
+	if attempts != 4 || len(*sleeps) != 3 {
+		t.Error(attempts, "attempts and", len(*sleeps), "retries, expected 4 and 3")
+	}
+
+	// Elapsed, every backoff ends after the budget.
+	attempts = 0
+	c.Retry = &Retry{MaxAttempts: 10, MaxElapsed: time.Nanosecond, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	c.DeleteVault("vault")
+	if attempts > 2 {
+		t.Error(attempts, "attempts, expected the budget to stop retries")
+	}
+
+	// Without retries.
+	attempts = 0
+	c.Retry = &Retry{MaxAttempts: 1}
+	c.DeleteVault("vault")
+	if attempts != 1 {
+		t.Error(attempts, "attempts without retries")
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	var attempts int
+	var status int
+	var code string
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			writeError(w, status, code)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	c.Retry = &Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		status  int
+		code    string
+		retried bool
+	}{
+		{http.StatusBadRequest, "ThrottlingException", true},
+		{http.StatusRequestTimeout, "RequestTimeoutException", true},
+		{http.StatusInternalServerError, "ServiceUnavailableException", true},
+		{http.StatusBadGateway, "", true},
+		{http.StatusNotFound, "ResourceNotFoundException", false},
+		{http.StatusBadRequest, "InvalidParameterValueException", false},
+	}
+	for _, test := range tests {
+		attempts, status, code = 0, test.status, test.code
+		err := c.CreateVault("vault")
+		if retried := attempts == 2; retried != test.retried || (err == nil) != test.retried {
+			t.Errorf("%d %s: %d attempts, %v", test.status, test.code, attempts, err)
+		}
+	}
+
+	// Operations that aren't idempotent aren't retried.
+	attempts, status, code = 0, http.StatusServiceUnavailable, "ServiceUnavailableException"
+	if _, err := c.InitiateMultipart("vault", 1<<20, ""); err == nil || attempts != 1 {
+		t.Error(attempts, "attempts of non-idempotent operation", err)
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	var attempts int
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	c.Retry = &Retry{MaxAttempts: 2}
+
+	if err := c.DeleteArchive("vault", "archive"); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Error(attempts, "attempts, expected 2")
+	}
+}
+
+func TestRetryUploadArchive(t *testing.T) {
+	// The handler may still be running when the reset connection fails the
+	// request, so the attempts are locked.
+	var mu sync.Mutex
+	var attempts int
+	c, _, _ := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		if first {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		writeError(w, http.StatusServiceUnavailable, "ServiceUnavailableException")
+	})
+	c.Retry = &Retry{MaxAttempts: 3}
+
+	// Neither a connection reset nor a retryable response is retried, as the
+	// archive may have been stored.
+	for i := 1; i <= 2; i++ {
+		if _, err := c.UploadArchive("vault", bytes.NewReader([]byte("archive")), ""); err == nil {
+			t.Error("expected error")
+		}
+		mu.Lock()
+		if attempts != i {
+			t.Error(attempts, "attempts, expected", i)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := &Retry{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt < 100; attempt++ {
+		limit := r.MaxDelay
+		if attempt < 5 {
+			limit = r.BaseDelay << uint(attempt-1)
+		}
+		for i := 0; i < 20; i++ {
+			if d := r.backoff(attempt); d < 0 || d > limit {
+				t.Fatalf("attempt %d: backoff %v, limit %v", attempt, d, limit)
+			}
+		}
+	}
+	if d := (&Retry{}).backoff(1); d != 0 {
+		t.Error("backoff without delays", d)
+	}
+}
@@ -1,9 +1,8 @@
 package glacier
 
 import (
+	"context"
 	"encoding/json"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
@@ -58,27 +57,23 @@ type Notifications struct {
 // and it has no further effect after the first time Amazon Glacier creates the
 // specified vault.
 func (c *Connection) CreateVault(name string) error {
+	return c.CreateVaultContext(context.Background(), name)
+}
+
+// CreateVaultContext is like CreateVault but uses ctx to cancel the request,
+// and any retries or waits for the rate limiter.
+func (c *Connection) CreateVaultContext(ctx context.Context, name string) error {
 	// Build request.
-	request, err := http.NewRequest("PUT", c.vault(name), nil)
+	r, err := c.newRequest(ctx, "CreateVault", "PUT", c.vault(name))
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusCreated)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusCreated {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -92,27 +87,23 @@ func (c *Connection) CreateVault(name string) error {
 //
 // This operation is idempotent.
 func (c *Connection) DeleteVault(name string) error {
+	return c.DeleteVaultContext(context.Background(), name)
+}
+
+// DeleteVaultContext is like DeleteVault but uses ctx to cancel the request,
+// and any retries or waits for the rate limiter.
+func (c *Connection) DeleteVaultContext(ctx context.Context, name string) error {
 	// Build request.
-	request, err := http.NewRequest("DELETE", c.vault(name), nil)
+	r, err := c.newRequest(ctx, "DeleteVault", "DELETE", c.vault(name))
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -127,38 +118,28 @@ func (c *Connection) DeleteVault(name string) error {
 // archive from a vault, and then immediately send a Describe Vault request, the
 // response might not reflect the changes.
 func (c *Connection) DescribeVault(name string) (*Vault, error) {
+	return c.DescribeVaultContext(context.Background(), name)
+}
+
+// DescribeVaultContext is like DescribeVault but uses ctx to cancel the
+// request, and any retries or waits for the rate limiter.
+func (c *Connection) DescribeVaultContext(ctx context.Context, name string) (*Vault, error) {
 	// Build request.
-	request, err := http.NewRequest("GET", c.vault(name), nil)
+	r, err := c.newRequest(ctx, "DescribeVault", "GET", c.vault(name))
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, nil)
+	var v vault
+	r.output = &v
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, aws.ParseError(response)
-	}
 
 	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var v vault
-	err = json.Unmarshal(body, &v)
-	if err != nil {
-		return nil, err
-	}
-
 	var result Vault
 	result.CreationDate, err = time.Parse(time.RFC3339, v.CreationDate)
 	if err != nil {
@@ -190,6 +171,12 @@ func (c *Connection) DescribeVault(name string) (*Vault, error) {
 // number of vaults returned in the response by specifying the limit parameter
 // in the request.
 func (c *Connection) ListVaults(marker string, limit int) ([]Vault, string, error) {
+	return c.ListVaultsContext(context.Background(), marker, limit)
+}
+
+// ListVaultsContext is like ListVaults but uses ctx to cancel the request, and
+// any retries or waits for the rate limiter.
+func (c *Connection) ListVaultsContext(ctx context.Context, marker string, limit int) ([]Vault, string, error) {
 	// Build request.
 	parameters := parameters{}
 	if limit > 0 {
@@ -205,27 +192,7 @@ func (c *Connection) ListVaults(marker string, limit int) ([]Vault, string, erro
 	vaultURL := c.vault("")
 	vaultURL = vaultURL[:len(vaultURL)-1]
 
-	request, err := http.NewRequest("GET", vaultURL+parameters.encode(), nil)
-	if err != nil {
-		return nil, "", err
-	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
-
-	// Perform request.
-	response, err := c.client().Do(request)
-	if err != nil {
-		return nil, "", err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, "", aws.ParseError(response)
-	}
-
-	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
+	r, err := c.newRequest(ctx, "ListVaults", "GET", vaultURL+parameters.encode())
 	if err != nil {
 		return nil, "", err
 	}
@@ -234,11 +201,15 @@ func (c *Connection) ListVaults(marker string, limit int) ([]Vault, string, erro
 		Marker    *string
 		VaultList []vault
 	}
-	err = json.Unmarshal(body, &vaults)
+	r.output = &vaults
+
+	// Perform request.
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Parse success response.
 	result := make([]Vault, len(vaults.VaultList))
 	for i, v := range vaults.VaultList {
 		result[i].CreationDate, err = time.Parse(time.RFC3339, v.CreationDate)
@@ -281,32 +252,30 @@ func (c *Connection) ListVaults(marker string, limit int) ([]Vault, string, erro
 // specific to a vault; therefore, it is also referred to as a vault
 // subresource.
 func (c *Connection) SetVaultNotifications(name string, n *Notifications) error {
+	return c.SetVaultNotificationsContext(context.Background(), name, n)
+}
+
+// SetVaultNotificationsContext is like SetVaultNotifications but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) SetVaultNotificationsContext(ctx context.Context, name string, n *Notifications) error {
 	// Build request.
 	body, err := json.Marshal(n)
 	if err != nil {
 		return err
 	}
 
-	request, err := http.NewRequest("PUT", c.vault(name)+"/notification-configuration", nil)
+	r, err := c.newRequest(ctx, "SetVaultNotifications", "PUT", c.vault(name)+"/notification-configuration")
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
 
-	c.Signature.Sign(request, aws.MemoryPayload(body))
+	r.Payload = aws.MemoryPayload(body)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
@@ -316,39 +285,28 @@ func (c *Connection) SetVaultNotifications(name string, n *Notifications) error
 // the vault. If notification configuration for a vault is not set, the
 // operation returns a 404 Not Found error.
 func (c *Connection) GetVaultNotifications(name string) (*Notifications, error) {
+	return c.GetVaultNotificationsContext(context.Background(), name)
+}
+
+// GetVaultNotificationsContext is like GetVaultNotifications but uses ctx to
+// cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) GetVaultNotificationsContext(ctx context.Context, name string) (*Notifications, error) {
 	// Build request.
 	var results Notifications
 
-	request, err := http.NewRequest("GET", c.vault(name)+"/notification-configuration", nil)
+	r, err := c.newRequest(ctx, "GetVaultNotifications", "GET", c.vault(name)+"/notification-configuration")
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
+	r.output = &results
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusOK)
 	if err != nil {
 		return nil, err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return nil, aws.ParseError(response)
-	}
 
 	// Parse success response.
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(body, &results)
-	if err != nil {
-		return nil, err
-	}
-
 	return &results, nil
 }
 
@@ -358,27 +316,23 @@ func (c *Connection) GetVaultNotifications(name string) (*Notifications, error)
 // receive some notifications for a short time after you send the delete
 // request.
 func (c *Connection) DeleteVaultNotifications(name string) error {
+	return c.DeleteVaultNotificationsContext(context.Background(), name)
+}
+
+// DeleteVaultNotificationsContext is like DeleteVaultNotifications but uses ctx
+// to cancel the request, and any retries or waits for the rate limiter.
+func (c *Connection) DeleteVaultNotificationsContext(ctx context.Context, name string) error {
 	// Build request.
-	request, err := http.NewRequest("DELETE", c.vault(name)+"/notification-configuration", nil)
+	r, err := c.newRequest(ctx, "DeleteVaultNotifications", "DELETE", c.vault(name)+"/notification-configuration")
 	if err != nil {
 		return err
 	}
-	request.Header.Add("x-amz-glacier-version", "2012-06-01")
-
-	c.Signature.Sign(request, nil)
 
 	// Perform request.
-	response, err := c.client().Do(request)
+	err = c.run(r, http.StatusNoContent)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusNoContent {
-		return aws.ParseError(response)
-	}
-
-	io.Copy(ioutil.Discard, response.Body)
 
 	// Parse success response.
 	return nil
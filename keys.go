@@ -0,0 +1,102 @@
+package aws
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+)
+
+// maxSigningKeys is how many signing keys a signature and the signatures
+// created from it with ForScope cache.
+const maxSigningKeys = 32
+
+// signingKeys caches derived signing keys by credentials and scope, so that
+// signatures for several regions and services sharing credentials don't derive
+// the same keys again.
+type signingKeys struct {
+	mu   sync.Mutex
+	keys map[signingKeyScope][sha256.Size]byte
+}
+
+// signingKeyScope identifies a signing key. The secret is hashed so it isn't
+// kept in memory.
+type signingKeyScope struct {
+	accessID string
+	secret   [sha256.Size]byte
+	date     string
+	region   string
+	service  string
+}
+
+func newSigningKeys() *signingKeys {
+	return &signingKeys{keys: make(map[signingKeyScope][sha256.Size]byte)}
+}
+
+// get returns the signing key for the credentials and scope, deriving it if it
+// isn't cached. When the cache is full keys for other dates are dropped, or
+// all of them if they are all for the date.
+func (c *signingKeys) get(accessID, secret, date, region, service string) [sha256.Size]byte {
+	scope := signingKeyScope{accessID, sha256.Sum256([]byte(secret)), date, region, service}
+
+	c.mu.Lock()
+	key, ok := c.keys[scope]
+	c.mu.Unlock()
+	if ok {
+		return key
+	}
+
+	key = deriveSigningKey(secret, date, region, service)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.keys) >= maxSigningKeys {
+		for i := range c.keys {
+			if i.date != date {
+				delete(c.keys, i)
+			}
+		}
+		if len(c.keys) >= maxSigningKeys {
+			c.keys = make(map[signingKeyScope][sha256.Size]byte)
+		}
+	}
+	c.keys[scope] = key
+	return key
+}
+
+// ForScope returns a signature for another region and service using the same
+// credentials provider, clock, and clock skew correction. The signatures share
+// the clock offset of s rather than their own, see AdjustClock. The options
+// are not copied as they depend on the service. Signing keys are cached and
+// shared between the signatures so each is derived once a day.
+//
+// Returns an error if the signature has no Provider, or the provider's error.
+func (s *Signature) ForScope(r *Region, service string) (*Signature, error) {
+	parent := s
+	if s.parent != nil {
+		parent = s.parent
+	}
+	s.mu.Lock()
+	if s.keys == nil {
+		s.keys = newSigningKeys()
+	}
+	scoped := &Signature{
+		Region:           r,
+		Service:          service,
+		Provider:         s.Provider,
+		Clock:            s.Clock,
+		CorrectClockSkew: s.CorrectClockSkew,
+		keys:             s.keys,
+		parent:           parent,
+	}
+	s.mu.Unlock()
+
+	if scoped.Provider == nil {
+		return nil, errors.New("aws: signature has no credentials provider")
+	}
+	c, err := scoped.Provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	scoped.setCredentials(c, scoped.now().Format(ISO8601BasicFormatShort))
+	return scoped, nil
+}
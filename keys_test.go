@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Sign from many goroutines while the clock passes midnight, every request
+// must be signed consistently for the day of its date.
+func TestSignConcurrent(t *testing.T) {
+	clock := &testClock{now: time.Date(2011, time.September, 9, 23, 59, 59, 900e6, time.UTC)}
+	signature := NewSignature("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE", USEast1, "host")
+	signature.Clock = clock
+	signature.CorrectClockSkew = true
+
+	// The clock only starts once every worker has signed a request, and the
+	// workers keep signing until it has passed midnight, so both days are
+	// signed for while the workers race.
+	const workers = 8
+	var started sync.WaitGroup
+	started.Add(workers)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		started.Wait()
+		for i := 0; i < 6; i++ {
+			clock.Add(50 * time.Millisecond)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var once sync.Once
+			defer once.Do(started.Done)
+			for j := 0; ; j++ {
+				request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				err = signature.Sign(request, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				dateTime, _ := time.Parse(ISO8601BasicFormat, request.Header.Get("X-Amz-Date"))
+				err = verify(request, testLookup, dateTime)
+				if err != nil {
+					errs <- fmt.Errorf("%v: %s", err, request.Header.Get("Authorization"))
+					return
+				}
+				day := dateTime.Format(ISO8601BasicFormatShort)
+				mu.Lock()
+				seen[day]++
+				mu.Unlock()
+				once.Do(started.Done)
+
+				select {
+				case <-done:
+					if day == "20110910" {
+						return
+					}
+				default:
+				}
+
+				// Skew corrections race with signing too.
+				if j%10 == 0 {
+					response := &http.Response{Header: http.Header{"Date": {clock.Now().Format(http.TimeFormat)}}}
+					signature.AdjustClock(response, &Error{Code: "RequestTimeTooSkewed"})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	<-done
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if seen["20110909"] == 0 || seen["20110910"] == 0 {
+		t.Error("expected requests signed on both days", seen)
+	}
+}
+
+func TestForScope(t *testing.T) {
+	p := &countingProvider{StaticProvider: StaticProvider{AccessID: "access", Secret: "secret"}}
+	signature, err := NewSignatureFromProvider(p, USEast1, "glacier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		scoped, err := signature.ForScope(EU1, "s3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scoped.Region != EU1 || scoped.Service != "s3" || scoped.AccessID != signature.AccessID ||
+			!strings.HasSuffix(scoped.credentialScope(), "/eu-west-1/s3/aws4_request") {
+			t.Errorf("unexpected signature %+v", scoped)
+		}
+		if scoped.SigningKey != deriveSigningKey("secret", scoped.Date, "eu-west-1", "s3") {
+			t.Error("wrong signing key")
+		}
+	}
+	if n := len(signature.keys.keys); n != 2 {
+		t.Error(n, "signing keys cached, expected 2")
+	}
+
+	literal := &Signature{Region: USEast1, Service: "glacier"}
+	if _, err := literal.ForScope(EU1, "s3"); err == nil {
+		t.Error("expected error without provider")
+	}
+}
+
+func TestForScopeClockOffset(t *testing.T) {
+	clock := &testClock{now: time.Date(2011, time.September, 9, 23, 36, 0, 0, time.UTC)}
+	signature, err := NewSignatureFromProvider(&StaticProvider{AccessID: "access", Secret: "secret"}, USEast1, "glacier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature.Clock = clock
+	signature.CorrectClockSkew = true
+	scoped, err := signature.ForScope(EU1, "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := scoped.ForScope(EU1, "sqs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := func(s *Signature) string {
+		request, err := http.NewRequest("GET", "http://host.foo.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Sign(request, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request.Header.Get("X-Amz-Date")
+	}
+	for _, s := range []*Signature{signature, scoped, nested} {
+		if got := date(s); got != "20110909T233600Z" {
+			t.Error("X-Amz-Date is", got)
+		}
+	}
+
+	// Adjusting the clock of any of them, after their keys are cached, adjusts
+	// them all.
+	response := &http.Response{Header: http.Header{"Date": {"Sat, 10 Sep 2011 00:10:00 GMT"}}}
+	if !signature.AdjustClock(response, &Error{Code: "RequestTimeTooSkewed"}) {
+		t.Fatal("clock not adjusted")
+	}
+	for _, s := range []*Signature{signature, scoped, nested} {
+		if got := date(s); got != "20110910T001000Z" {
+			t.Error("X-Amz-Date is", got)
+		}
+	}
+	response.Header.Set("Date", "Fri, 09 Sep 2011 23:40:00 GMT")
+	if !nested.AdjustClock(response, &Error{Code: "RequestTimeTooSkewed"}) {
+		t.Fatal("clock not adjusted")
+	}
+	for _, s := range []*Signature{signature, scoped, nested} {
+		if got := date(s); got != "20110909T234000Z" {
+			t.Error("X-Amz-Date is", got)
+		}
+	}
+}
+
+func TestSigningKeysEviction(t *testing.T) {
+	keys := newSigningKeys()
+	for i := 0; i < maxSigningKeys; i++ {
+		keys.get("access", "secret", "20110909", "us-east-1", fmt.Sprint("service", i))
+	}
+	if len(keys.keys) != maxSigningKeys {
+		t.Fatal(len(keys.keys), "signing keys cached")
+	}
+
+	// Keys for the previous day are dropped first.
+	keys.get("access", "secret", "20110910", "us-east-1", "service0")
+	if len(keys.keys) != 1 {
+		t.Error(len(keys.keys), "signing keys cached after the date changed")
+	}
+
+	// A different secret for the same access key gets a different key.
+	if keys.get("access", "other", "20110910", "us-east-1", "service0") ==
+		keys.get("access", "secret", "20110910", "us-east-1", "service0") {
+		t.Error("signing key cached by access key only")
+	}
+}
@@ -0,0 +1,293 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInstanceMetadataEndpoint is the address of the EC2 instance
+	// metadata service.
+	DefaultInstanceMetadataEndpoint = "http://169.254.169.254"
+
+	// DefaultContainerEndpoint is the address of the ECS container
+	// credentials service that AWS_CONTAINER_CREDENTIALS_RELATIVE_URI is
+	// relative to.
+	DefaultContainerEndpoint = "http://169.254.170.2"
+
+	// instanceMetadataTokenTTL is how long the instance metadata session
+	// tokens are requested for.
+	instanceMetadataTokenTTL = 6 * time.Hour
+)
+
+// metadataClient is used by the metadata providers if they don't specify a
+// client. The services are local so a short timeout avoids hanging when not
+// running on EC2 or ECS.
+var metadataClient = &http.Client{Timeout: time.Second}
+
+// metadataCredentials is the JSON returned by both the instance metadata and
+// container credentials services.
+type metadataCredentials struct {
+	Code            string
+	Message         string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+// getMetadata performs the request and returns the body of a successful
+// response. A resource that is not found, such as the role of an instance
+// without one, results in ErrNoCredentials.
+func getMetadata(client *http.Client, request *http.Request) ([]byte, error) {
+	if client == nil {
+		client = metadataClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNoCredentials
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("aws: " + request.URL.String() + ": " + response.Status)
+	}
+	return body, nil
+}
+
+// parseMetadataCredentials parses the JSON credentials returned by the
+// metadata services.
+func parseMetadataCredentials(body []byte) (*Credentials, error) {
+	var mc metadataCredentials
+	err := json.Unmarshal(body, &mc)
+	if err != nil {
+		return nil, err
+	}
+	if mc.Code != "" && mc.Code != "Success" {
+		return nil, errors.New("aws: metadata credentials: " + mc.Code + ": " + mc.Message)
+	}
+	c := &Credentials{
+		AccessID:     mc.AccessKeyId,
+		Secret:       mc.SecretAccessKey,
+		SessionToken: mc.Token,
+	}
+	if mc.Expiration != "" {
+		c.Expiration, err = time.Parse(time.RFC3339, mc.Expiration)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// InstanceMetadataProvider provides the credentials of the role attached to
+// the EC2 instance using the instance metadata service (IMDSv2).
+type InstanceMetadataProvider struct {
+	// Client optionally specifies the HTTP client to use. If nil, a client
+	// with a short timeout is used.
+	Client *http.Client
+
+	// Endpoint optionally specifies the URL of the metadata service. If
+	// empty, DefaultInstanceMetadataEndpoint is used.
+	Endpoint string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewInstanceMetadataProvider returns a provider that fetches the instance's
+// role credentials and caches them until they are about to expire.
+func NewInstanceMetadataProvider() *CachedProvider {
+	return NewCachedProvider(&InstanceMetadataProvider{})
+}
+
+func (p *InstanceMetadataProvider) endpoint() string {
+	if p.Endpoint == "" {
+		return DefaultInstanceMetadataEndpoint
+	}
+	return p.Endpoint
+}
+
+// sessionToken returns the current session token, requesting a new one if it
+// has expired.
+func (p *InstanceMetadataProvider) sessionToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Now().Add(time.Minute).Before(p.expires) {
+		return p.token, nil
+	}
+
+	request, err := http.NewRequest("PUT", p.endpoint()+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	ttl := strconv.Itoa(int(instanceMetadataTokenTTL / time.Second))
+	request.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", ttl)
+
+	expires := time.Now().Add(instanceMetadataTokenTTL)
+	body, err := getMetadata(p.Client, request)
+	if err != nil {
+		return "", err
+	}
+	p.token = string(body)
+	p.expires = expires
+	return p.token, nil
+}
+
+// get requests the path from the metadata service using a session token.
+func (p *InstanceMetadataProvider) get(path string) ([]byte, error) {
+	token, err := p.sessionToken()
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", p.endpoint()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-aws-ec2-metadata-token", token)
+	return getMetadata(p.Client, request)
+}
+
+// Credentials fetches the credentials of the instance's role. It returns
+// ErrNoCredentials if the instance doesn't have a role.
+func (p *InstanceMetadataProvider) Credentials() (*Credentials, error) {
+	const path = "/latest/meta-data/iam/security-credentials/"
+
+	body, err := p.get(path)
+	if err != nil {
+		return nil, err
+	}
+	role := strings.TrimSpace(strings.SplitN(string(body), "\n", 2)[0])
+	if role == "" {
+		return nil, ErrNoCredentials
+	}
+
+	body, err = p.get(path + role)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadataCredentials(body)
+}
+
+// ContainerProvider provides the credentials of the ECS task's role from the
+// container credentials service.
+type ContainerProvider struct {
+	// Client optionally specifies the HTTP client to use. If nil, a client
+	// with a short timeout is used.
+	Client *http.Client
+
+	// Endpoint optionally specifies the URL of the credentials. If empty,
+	// AWS_CONTAINER_CREDENTIALS_FULL_URI is used, or
+	// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI relative to
+	// DefaultContainerEndpoint. So the authorization token isn't sent to
+	// another host, it must be https, or http to a loopback address or the ECS
+	// or EKS credentials service.
+	Endpoint string
+
+	// AuthorizationToken optionally specifies the Authorization header sent
+	// with the request. If empty, AWS_CONTAINER_AUTHORIZATION_TOKEN is used,
+	// or the contents of the file named by
+	// AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE.
+	AuthorizationToken string
+}
+
+// NewContainerProvider returns a provider that fetches the task's role
+// credentials and caches them until they are about to expire.
+func NewContainerProvider() *CachedProvider {
+	return NewCachedProvider(&ContainerProvider{})
+}
+
+// Credentials fetches the credentials of the task's role. It returns
+// ErrNoCredentials if no endpoint is configured.
+func (p *ContainerProvider) Credentials() (*Credentials, error) {
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	}
+	if endpoint == "" {
+		if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+			endpoint = DefaultContainerEndpoint + relative
+		}
+	}
+	if endpoint == "" {
+		return nil, ErrNoCredentials
+	}
+	if !allowedContainerEndpoint(endpoint) {
+		return nil, errors.New("aws: container credentials endpoint " + endpoint +
+			" is neither https nor a loopback or container credentials address")
+	}
+
+	token := p.AuthorizationToken
+	if token == "" {
+		token = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	}
+	if name := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); token == "" && name != "" {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+
+	body, err := getMetadata(p.Client, request)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadataCredentials(body)
+}
+
+// containerHosts are the addresses of the ECS and EKS container credentials
+// services, which are reached over http.
+var containerHosts = map[string]bool{
+	"169.254.170.2":  true,
+	"169.254.170.23": true,
+	"fd00:ec2::23":   true,
+}
+
+// allowedContainerEndpoint returns whether the container credentials may be
+// requested from the endpoint, like the AWS SDKs: it must use https, or http
+// to a loopback address or a container credentials service.
+func allowedContainerEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" || containerHosts[host] {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
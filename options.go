@@ -0,0 +1,25 @@
+package aws
+
+// SigningOptions control how a request is canonicalized for services that
+// don't follow the defaults, which suit Glacier and most other services. The
+// zero value is the defaults.
+//
+// S3 signs object keys as they are, so requests to it should be signed with
+// DisablePathNormalization, and it requires the X-Amz-Content-Sha256 header,
+// which UnsignedPayload sets if the payload is not to be signed.
+type SigningOptions struct {
+	// DisablePathNormalization signs the path as it is, without removing
+	// redundant slashes and "." and ".." segments. A trailing slash is always
+	// kept.
+	DisablePathNormalization bool
+
+	// DoubleURIEncode encodes each segment of the path twice, for services
+	// that sign the path as it is sent, which is already encoded once.
+	DoubleURIEncode bool
+
+	// UnsignedPayload sets the X-Amz-Content-Sha256 header to UNSIGNED-PAYLOAD
+	// and signs that instead of the payload's hash, so the body isn't read to
+	// sign the request. Presigned URLs are signed with it instead of the hash
+	// of an empty payload. It has no effect on a ChunkedPayload.
+	UnsignedPayload bool
+}
@@ -0,0 +1,88 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPresignExpires is the longest a presigned URL may be valid for.
+const MaxPresignExpires = 7 * 24 * time.Hour
+
+// emptyHash is the hex encoded SHA-256 hash of an empty payload, which is
+// used as the payload hash of presigned URLs unless the payload is unsigned.
+var emptyHash = []byte("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+
+// Presign uses signature s to create a presigned URL for the HTTP request.
+// Instead of setting the Authorization header the authentication information
+// is added to the URL's query string, allowing anyone holding the URL to make
+// the request until expires has elapsed without access to the signing key.
+// The request itself is not modified. Every header of the request is signed
+// and so must be sent along with the URL.
+//
+// Possible errors are an expiration that is not between one second and
+// MaxPresignExpires, an error from the signature's provider, invalid URL query
+// parameters (url.EscapeError), if the date header isn't in time.RFC1123
+// format (*time.ParseError), or ErrDateMismatch if the request's date isn't on
+// the signature's UTC day.
+func (s *Signature) Presign(r *http.Request, expires time.Duration) (*url.URL, error) {
+	if expires < time.Second || expires > MaxPresignExpires {
+		return nil, errors.New("aws: presign expiration must be between 1 second and 7 days")
+	}
+
+	state, err := s.state()
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	dateTime, err := requestDate(r, state.now)
+	if err != nil {
+		return nil, err
+	}
+
+	// As with Sign, the signing key is only valid for requests made on its
+	// day.
+	//
+	if dateTime.Format(ISO8601BasicFormatShort) != state.date {
+		return nil, ErrDateMismatch
+	}
+
+	headers := signedHeaders(r)
+	credential := state.credential
+
+	// Add the authorization parameters, other than the signature itself, to
+	// the query string so they are included in the canonical request. See
+	// http://docs.aws.amazon.com/general/latest/gr/sigv4-add-signature-to-request.html
+	//
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", state.accessID+"/"+credential)
+	query.Set("X-Amz-Date", dateTime.Format(ISO8601BasicFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", strings.Join(headers, ";"))
+	if state.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", state.sessionToken)
+	}
+
+	hash := emptyHash
+	if state.options.UnsignedPayload {
+		hash = []byte(unsignedPayload)
+	}
+
+	cr := canonicalRequest(r, query, headers, hash, state.options)
+	sts := stringToSign(algorithm, dateTime, credential, cr)
+
+	result := *r.URL
+	if result.Host == "" {
+		result.Host = r.Host
+	}
+	result.RawQuery = canonicalQueryString(query) + "&X-Amz-Signature=" + string(signature(state.signingKey, sts))
+	return &result, nil
+}
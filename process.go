@@ -0,0 +1,158 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessProvider provides credentials by running an external helper command,
+// following the credential_process convention of the shared config file. The
+// command must print JSON of the form:
+//
+//	{
+//	  "Version": 1,
+//	  "AccessKeyId": "an AWS access key",
+//	  "SecretAccessKey": "your AWS secret access key",
+//	  "SessionToken": "the AWS session token for temporary credentials",
+//	  "Expiration": "RFC3339 timestamp for when the credentials expire"
+//	}
+//
+// SessionToken and Expiration are optional.
+type ProcessProvider struct {
+	// Command is the command line to run. It is split into arguments like a
+	// shell would, honoring quotes and backslash escapes, but is not run by a
+	// shell.
+	Command string
+}
+
+// NewProcessProvider returns a provider that runs the command and caches the
+// credentials until they are about to expire.
+func NewProcessProvider(command string) *CachedProvider {
+	return NewCachedProvider(&ProcessProvider{Command: command})
+}
+
+// ProcessError is returned when a credential process fails or its output
+// can't be understood.
+type ProcessError struct {
+	Command string
+	Err     error
+	Stderr  string // trimmed standard error of the command, if any
+}
+
+func (e *ProcessError) Error() string {
+	s := "aws: credential process " + strconv.Quote(e.Command) + ": " + e.Err.Error()
+	if e.Stderr != "" {
+		s += ": " + e.Stderr
+	}
+	return s
+}
+
+// Credentials runs the command and parses the credentials it prints.
+func (p *ProcessProvider) Credentials() (*Credentials, error) {
+	args, err := splitCommand(p.Command)
+	if err != nil {
+		return nil, &ProcessError{Command: p.Command, Err: err}
+	}
+	if len(args) == 0 {
+		return nil, &ProcessError{Command: p.Command, Err: errors.New("empty command")}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, &ProcessError{Command: p.Command, Err: err, Stderr: strings.TrimSpace(stderr.String())}
+	}
+
+	var output struct {
+		Version         int
+		AccessKeyId     string
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      string
+	}
+	err = json.Unmarshal(stdout.Bytes(), &output)
+	if err != nil {
+		return nil, &ProcessError{Command: p.Command, Err: err}
+	}
+	if output.Version != 1 {
+		return nil, &ProcessError{Command: p.Command,
+			Err: errors.New("unsupported version " + strconv.Itoa(output.Version))}
+	}
+	if output.AccessKeyId == "" || output.SecretAccessKey == "" {
+		return nil, &ProcessError{Command: p.Command, Err: errors.New("missing AccessKeyId or SecretAccessKey")}
+	}
+
+	c := &Credentials{
+		AccessID:     output.AccessKeyId,
+		Secret:       output.SecretAccessKey,
+		SessionToken: output.SessionToken,
+	}
+	if output.Expiration != "" {
+		c.Expiration, err = time.Parse(time.RFC3339, output.Expiration)
+		if err != nil {
+			return nil, &ProcessError{Command: p.Command, Err: err}
+		}
+	}
+	return c, nil
+}
+
+// splitCommand splits a command line into arguments at unquoted whitespace.
+// Single quotes preserve everything they enclose, double quotes preserve all
+// but backslash escapes, and elsewhere a backslash escapes the next character.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg bytes.Buffer
+	inArg := false
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\\':
+			if i+1 == len(command) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			arg.WriteByte(command[i])
+			inArg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
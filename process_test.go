@@ -0,0 +1,149 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess isn't a real test, it is the credential process run by
+// the other tests. The mode is the argument after "--".
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("AWS_TEST_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		os.Exit(2)
+	}
+
+	switch args[1] {
+	case "static":
+		fmt.Print(`{"Version": 1, "AccessKeyId": "access", "SecretAccessKey": "secret"}`)
+	case "temporary":
+		// Count the calls in a file so the caching can be checked.
+		name := args[2]
+		data, _ := ioutil.ReadFile(name)
+		n, _ := strconv.Atoi(string(data))
+		n++
+		ioutil.WriteFile(name, []byte(strconv.Itoa(n)), 0600)
+		fmt.Printf(`{"Version": 1, "AccessKeyId": "access", "SecretAccessKey": "secret",
+			"SessionToken": "token%d", "Expiration": %q}`, n,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	case "version":
+		fmt.Print(`{"Version": 2, "AccessKeyId": "access", "SecretAccessKey": "secret"}`)
+	case "garbage":
+		fmt.Print(`not json`)
+	case "fail":
+		fmt.Fprintln(os.Stderr, "token expired, please log in")
+		os.Exit(1)
+	}
+}
+
+func helperCommand(t *testing.T, args ...string) string {
+	t.Setenv("AWS_TEST_HELPER_PROCESS", "1")
+	return strconv.Quote(os.Args[0]) + " -test.run=TestHelperProcess -- " + strings.Join(args, " ")
+}
+
+func TestProcessProvider(t *testing.T) {
+	c, err := (&ProcessProvider{Command: helperCommand(t, "static")}).Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "access" || c.Secret != "secret" || c.SessionToken != "" || !c.Expiration.IsZero() {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+
+	counter := filepath.Join(t.TempDir(), "counter")
+	p := NewProcessProvider(helperCommand(t, "temporary", strconv.Quote(counter)))
+	for i := 0; i < 3; i++ {
+		c, err = p.Credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SessionToken != "token1" || time.Until(c.Expiration) < 59*time.Minute {
+			t.Errorf("unexpected credentials %+v", c)
+		}
+	}
+
+	for _, mode := range []string{"version", "garbage", "fail"} {
+		_, err = (&ProcessProvider{Command: helperCommand(t, mode)}).Credentials()
+		e, ok := err.(*ProcessError)
+		if !ok {
+			t.Errorf("%s: expected *ProcessError, got %v", mode, err)
+			continue
+		}
+		if mode == "fail" && e.Stderr != "token expired, please log in" {
+			t.Errorf("%s: unexpected stderr %q", mode, e.Stderr)
+		}
+	}
+
+	_, err = (&ProcessProvider{Command: "/nonexistent/credential-helper"}).Credentials()
+	if _, ok := err.(*ProcessError); !ok {
+		t.Error("expected *ProcessError, got", err)
+	}
+}
+
+func TestSharedCredentialsProviderProcess(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	counter := filepath.Join(dir, "counter")
+	err := ioutil.WriteFile(config, []byte("[profile helper]\ncredential_process = "+
+		helperCommand(t, "temporary", strconv.Quote(counter))+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &SharedCredentialsProvider{Profile: "helper", Filename: config + ".missing", ConfigFilename: config}
+	for i := 0; i < 3; i++ {
+		c, err := p.Credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.AccessID != "access" || c.Secret != "secret" || c.SessionToken != "token1" {
+			t.Errorf("unexpected credentials %+v", c)
+		}
+	}
+	if data, _ := ioutil.ReadFile(counter); string(data) != "1" {
+		t.Errorf("credential process ran %s times, expected 1", data)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+	}{
+		{"helper", []string{"helper"}},
+		{"  helper   --profile  work ", []string{"helper", "--profile", "work"}},
+		{`"/opt/my tools/helper" 'a b' c\ d`, []string{"/opt/my tools/helper", "a b", "c d"}},
+		{`helper "say \"hi\"" 'back\slash' ""`, []string{"helper", `say "hi"`, `back\slash`, ""}},
+		{"", nil},
+	}
+	for _, v := range cases {
+		got, err := splitCommand(v.input)
+		if err != nil {
+			t.Errorf("%q: %v", v.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%q: got %q, want %q", v.input, got, v.want)
+		}
+	}
+
+	for _, v := range []string{`"unterminated`, `'unterminated`, `trailing\`} {
+		if _, err := splitCommand(v); err == nil {
+			t.Errorf("%q: expected error", v)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package aws
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrProfileNotFound is returned when a named profile is in neither the
+// shared credentials file nor the shared config file.
+var ErrProfileNotFound = errors.New("aws: profile not found")
+
+// Profile is a named profile from the shared credentials and config files
+// (~/.aws/credentials and ~/.aws/config).
+type Profile struct {
+	Name string
+
+	// Region is the profile's region, or nil if the profile doesn't have a
+	// region or it isn't one of Regions. The region's name is always in
+	// Values.
+	Region *Region
+
+	// Credentials from the profile's aws_access_key_id,
+	// aws_secret_access_key, and aws_session_token.
+	Credentials
+
+	// Values contains all of the profile's keys. Keys from the credentials
+	// file take precedence over the same keys in the config file. Keys of
+	// nested sections are joined to their parent with a period, for example
+	// "s3.max_concurrent_requests".
+	Values map[string]string
+}
+
+// profileName returns name, or if it is empty the AWS_PROFILE environment
+// variable, or "default".
+func profileName(name string) string {
+	if name != "" {
+		return name
+	}
+	if name = os.Getenv("AWS_PROFILE"); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// sharedFilename returns name, or if it is empty the environment variable env,
+// or the file base in the .aws directory of the user's home directory.
+func sharedFilename(name, env, base string) string {
+	if name != "" {
+		return name
+	}
+	if name = os.Getenv(env); name != "" {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", base)
+}
+
+// LoadProfile loads the named profile from the shared credentials and config
+// files. If name is empty the AWS_PROFILE environment variable is used, or
+// "default" if it is unset. The files are located by the
+// AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE environment variables, or
+// ~/.aws/credentials and ~/.aws/config if they are unset.
+//
+// Returns the profile, ErrProfileNotFound, or an error reading the files.
+func LoadProfile(name string) (*Profile, error) {
+	return LoadProfileFiles(name, "", "")
+}
+
+// LoadProfileFiles is like LoadProfile but reads the given credentials and
+// config files. Either file may be empty to use the default, and neither file
+// has to exist.
+func LoadProfileFiles(name, credentialsFile, configFile string) (*Profile, error) {
+	name = profileName(name)
+	credentialsFile = sharedFilename(credentialsFile, "AWS_SHARED_CREDENTIALS_FILE", "credentials")
+	configFile = sharedFilename(configFile, "AWS_CONFIG_FILE", "config")
+
+	// Sections in the config file other than the default are prefixed with
+	// "profile ".
+	configSection := name
+	if name != "default" {
+		configSection = "profile " + name
+	}
+
+	p := &Profile{Name: name, Values: make(map[string]string)}
+	found := false
+	for _, f := range []struct {
+		name    string
+		section string
+	}{
+		{configFile, configSection},
+		{credentialsFile, name},
+	} {
+		sections, err := parseINIFile(f.name)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := sections[f.section]
+		if !ok && f.section != name {
+			// Be lenient and accept "[name]" in the config file too.
+			values, ok = sections[name]
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		for k, v := range values {
+			p.Values[k] = v
+		}
+	}
+	if !found {
+		return nil, ErrProfileNotFound
+	}
+
+	p.AccessID = p.Values["aws_access_key_id"]
+	p.Secret = p.Values["aws_secret_access_key"]
+	p.SessionToken = p.Values["aws_session_token"]
+	p.Region = FindRegion(p.Values["region"])
+
+	return p, nil
+}
+
+// parseINIFile parses the named file, see parseINI. A file that doesn't exist
+// has no sections.
+func parseINIFile(name string) (map[string]map[string]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseINI(file)
+}
+
+// parseINI parses the INI format used by the shared credentials and config
+// files into a map of sections, each a map of keys to values. Blank lines and
+// lines starting with '#' or ';' are ignored. An indented line following a key
+// with an empty value belongs to a nested section and is stored as
+// "parent.key".
+func parseINI(r io.Reader) (map[string]map[string]string, error) {
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+	var parent string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' {
+			continue
+		}
+
+		if trimmed[0] == '[' {
+			end := strings.IndexByte(trimmed, ']')
+			if end < 0 {
+				return nil, &ParseProfileError{line, "unterminated section"}
+			}
+			name := strings.Join(strings.Fields(trimmed[1:end]), " ")
+			section = sections[name]
+			if section == nil {
+				section = make(map[string]string)
+				sections[name] = section
+			}
+			parent = ""
+			continue
+		}
+
+		if section == nil {
+			return nil, &ParseProfileError{line, "key outside of a section"}
+		}
+		i := strings.IndexByte(trimmed, '=')
+		if i < 0 {
+			return nil, &ParseProfileError{line, "expected key = value"}
+		}
+		key := strings.TrimSpace(trimmed[:i])
+		value := strings.TrimSpace(trimmed[i+1:])
+
+		nested := text[0] == ' ' || text[0] == '\t'
+		switch {
+		case nested && parent != "":
+			section[parent+"."+key] = value
+		case value == "":
+			parent = key
+		default:
+			section[key] = value
+			parent = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// ParseProfileError is returned when a shared credentials or config file is
+// malformed.
+type ParseProfileError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseProfileError) Error() string {
+	return "aws: profile line " + strconv.Itoa(e.Line) + ": " + e.Message
+}
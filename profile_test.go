@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCredentialsFile = `# comment
+[default]
+aws_access_key_id = default access
+aws_secret_access_key=default secret
+
+; another comment
+[work]
+aws_access_key_id = work access
+aws_secret_access_key = work secret
+aws_session_token = work token
+`
+
+const testConfigFile = `[default]
+region = us-west-2
+
+[profile work]
+region = eu-west-1
+aws_access_key_id = overridden
+s3 =
+  max_concurrent_requests = 20
+output = json
+
+[profile  elsewhere ]
+region = mars-north-1
+aws_access_key_id = elsewhere access
+aws_secret_access_key = elsewhere secret
+`
+
+func writeProfileFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	config := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(credentials, []byte(testCredentialsFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config, []byte(testConfigFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return credentials, config
+}
+
+func TestLoadProfile(t *testing.T) {
+	credentials, config := writeProfileFiles(t)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", config)
+	t.Setenv("AWS_PROFILE", "")
+
+	p, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "default" || p.AccessID != "default access" ||
+		p.Secret != "default secret" || p.Region != USWest2 {
+		t.Errorf("unexpected default profile %+v", p)
+	}
+
+	t.Setenv("AWS_PROFILE", "work")
+	p, err = LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "work" || p.AccessID != "work access" || p.Secret != "work secret" ||
+		p.SessionToken != "work token" || p.Region != EU1 {
+		t.Errorf("unexpected work profile %+v", p)
+	}
+	if p.Values["s3.max_concurrent_requests"] != "20" || p.Values["output"] != "json" {
+		t.Errorf("unexpected work values %v", p.Values)
+	}
+
+	p, err = LoadProfile("elsewhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AccessID != "elsewhere access" || p.Region != nil || p.Values["region"] != "mars-north-1" {
+		t.Errorf("unexpected elsewhere profile %+v", p)
+	}
+
+	if _, err = LoadProfile("missing"); err != ErrProfileNotFound {
+		t.Error("expected ErrProfileNotFound, got", err)
+	}
+
+	dir := t.TempDir()
+	_, err = LoadProfileFiles("default", filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+	if err != ErrProfileNotFound {
+		t.Error("expected ErrProfileNotFound for missing files, got", err)
+	}
+}
+
+func TestParseINIErrors(t *testing.T) {
+	for _, v := range []string{
+		"[default",
+		"key = value",
+		"[default]\nkey",
+	} {
+		_, err := parseINI(strings.NewReader(v))
+		if _, ok := err.(*ParseProfileError); !ok {
+			t.Errorf("%q: expected *ParseProfileError, got %v", v, err)
+		}
+	}
+}
+
+func TestSharedCredentialsProvider(t *testing.T) {
+	credentials, config := writeProfileFiles(t)
+	p := &SharedCredentialsProvider{Profile: "work", Filename: credentials, ConfigFilename: config}
+	c, err := p.Credentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessID != "work access" || c.Secret != "work secret" || c.SessionToken != "work token" {
+		t.Errorf("unexpected credentials %+v", c)
+	}
+
+	p.Profile = "missing"
+	if _, err = p.Credentials(); err != ErrNoCredentials {
+		t.Error("expected ErrNoCredentials, got", err)
+	}
+}
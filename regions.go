@@ -41,3 +41,14 @@ var (
 )
 
 var Regions = []*Region{USEast1, USWest1, USWest2, EU1, EU2, AsiaPacific1, AsiaPacific2}
+
+// FindRegion returns the region in Regions with the canonical name, or nil if
+// there is no such region.
+func FindRegion(name string) *Region {
+	for _, r := range Regions {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// asymmetricAlgorithm is the signing algorithm of SigV4a.
+const asymmetricAlgorithm = "AWS4-ECDSA-P256-SHA256"
+
+// AsymmetricSignature signs requests with SigV4a, the asymmetric variant of
+// signature version 4 used by multi-region access points. Instead of a signing
+// key for one day, region, and service it signs with an ECDSA P-256 key derived
+// from the secret key, and a request is valid in any of the regions of its
+// region set, which is sent in the X-Amz-Region-Set header. "*" is every
+// region.
+//
+// If Provider is set new credentials are requested from it when they are
+// about to expire. The time requests are signed for is taken from Clock, or
+// the system clock if it is nil. Options control how requests are
+// canonicalized, S3 multi-region access points need the options for S3.
+//
+// An AsymmetricSignature is safe for concurrent use by multiple goroutines
+// once created, provided its fields are not modified directly.
+type AsymmetricSignature struct {
+	AccessID     string
+	RegionSet    []string
+	Service      string
+	PrivateKey   *ecdsa.PrivateKey
+	Provider     CredentialsProvider
+	SessionToken string
+	Expiration   time.Time
+	Clock        Clock
+	Options      SigningOptions
+
+	mu sync.Mutex
+}
+
+// NewAsymmetricSignature creates a new SigV4a signature from the secret key
+// and access key for the region set and service.
+func NewAsymmetricSignature(secret, access string, regionSet []string, service string) (*AsymmetricSignature, error) {
+	c := &Credentials{AccessID: access, Secret: secret}
+	return NewAsymmetricSignatureFromProvider((*StaticProvider)(c), regionSet, service)
+}
+
+// NewAsymmetricSignatureFromProvider creates a new SigV4a signature using the
+// credentials from the provider for the region set and service.
+//
+// Returns the signature or the provider's error.
+func NewAsymmetricSignatureFromProvider(p CredentialsProvider, regionSet []string, service string) (*AsymmetricSignature, error) {
+	c, err := p.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	s := &AsymmetricSignature{
+		RegionSet: regionSet,
+		Service:   service,
+		Provider:  p,
+	}
+	err = s.setCredentials(c)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// setCredentials sets the signature's credentials and derives the private key
+// for them.
+func (s *AsymmetricSignature) setCredentials(c *Credentials) error {
+	key, err := DeriveAsymmetricKey(c.Secret, c.AccessID)
+	if err != nil {
+		return err
+	}
+	s.AccessID = c.AccessID
+	s.SessionToken = c.SessionToken
+	s.Expiration = c.Expiration
+	s.PrivateKey = key
+	return nil
+}
+
+// DeriveAsymmetricKey derives the ECDSA P-256 key SigV4a signs with from the
+// secret and access keys. The private key is found with the NIST SP 800-108
+// counter mode key derivation function using HMAC-SHA256, retrying with an
+// incremented external counter until a candidate is a valid P-256 scalar.
+func DeriveAsymmetricKey(secret, access string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+	key := []byte("AWS4A" + secret)
+
+	for counter := 1; counter <= 0xff; counter++ {
+
+		// The fixed input is the label, a zero byte, the context (the access
+		// key and the external counter), and the length of the key in bits.
+		var fixed bytes.Buffer
+		fixed.WriteString(asymmetricAlgorithm)
+		fixed.WriteByte(0)
+		fixed.WriteString(access)
+		fixed.WriteByte(byte(counter))
+		binary.Write(&fixed, binary.BigEndian, uint32(256))
+
+		// A single block with the internal counter of 1 is enough for 256
+		// bits.
+		h := hmac.New(sha256.New, key)
+		binary.Write(h, binary.BigEndian, uint32(1))
+		h.Write(fixed.Bytes())
+		candidate := new(big.Int).SetBytes(h.Sum(nil))
+		if candidate.Cmp(nMinusTwo) > 0 {
+			continue
+		}
+
+		d := candidate.Add(candidate, big.NewInt(1))
+		private, err := ecdh.P256().NewPrivateKey(d.FillBytes(make([]byte, 32)))
+		if err != nil {
+			return nil, err
+		}
+		public := private.PublicKey().Bytes() // uncompressed, 0x04 X Y
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: d,
+		}, nil
+	}
+	return nil, errors.New("aws: exhausted counter deriving the SigV4a key")
+}
+
+// credentialScope returns the date, service, and termination string
+// ("aws4_request") separated by slashes. Unlike signature version 4 the scope
+// doesn't include a region.
+func (s *AsymmetricSignature) credentialScope(date string) string {
+	return date + "/" + s.Service + "/aws4_request"
+}
+
+// asymmetricState is a consistent copy of what requests are signed with.
+type asymmetricState struct {
+	accessID     string
+	sessionToken string
+	key          *ecdsa.PrivateKey
+	options      SigningOptions
+	now          time.Time
+}
+
+// state refreshes the credentials if they are about to expire and returns a
+// copy of what to sign with at the current time.
+func (s *AsymmetricSignature) state() (*asymmetricState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clock := s.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	now := clock.Now().UTC()
+	if s.Provider != nil && (s.PrivateKey == nil || expiring(s.Expiration, now, ExpiryWindow)) {
+		c, err := s.Provider.Credentials()
+		if err != nil {
+			return nil, err
+		}
+		err = s.setCredentials(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &asymmetricState{
+		accessID:     s.AccessID,
+		sessionToken: s.SessionToken,
+		key:          s.PrivateKey,
+		options:      s.Options,
+		now:          now,
+	}, nil
+}
+
+// Sign uses signature s to sign the HTTP request with SigV4a. It sets or
+// replaces the Authorization header, sets the X-Amz-Region-Set header, the
+// X-Amz-Security-Token header if the signature has a session token, and the
+// X-Amz-Date header to now if the request has neither an X-Amz-Date nor a
+// Date header. The optional payload is used as by Signature.Sign, chunked
+// payloads are not supported.
+//
+// Possible errors are an error from the signature's provider, invalid URL
+// query parameters (url.EscapeError), a date header in the wrong format
+// (*time.ParseError), or an error when calculating the payload's hash.
+func (s *AsymmetricSignature) Sign(r *http.Request, payload Payload) error {
+	return s.sign(r, payload, nil)
+}
+
+// SignWithDebug signs the request like Sign and returns the canonical
+// request, string to sign, signed headers, and Authorization header it used.
+func (s *AsymmetricSignature) SignWithDebug(r *http.Request, payload Payload) (*SigningDebug, error) {
+	debug := new(SigningDebug)
+	err := s.sign(r, payload, debug)
+	if err != nil {
+		return nil, err
+	}
+	return debug, nil
+}
+
+// sign signs the request, recording what was signed in debug if it isn't nil.
+func (s *AsymmetricSignature) sign(r *http.Request, payload Payload, debug *SigningDebug) error {
+	if _, ok := payload.(*chunkedPayload); ok {
+		return errors.New("aws: chunked payloads can't be signed with SigV4a")
+	}
+
+	state, err := s.state()
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+
+	if state.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", state.sessionToken)
+	}
+	r.Header.Set("X-Amz-Region-Set", strings.Join(s.RegionSet, ","))
+	if state.options.UnsignedPayload {
+		r.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+
+	dateTime, ok, err := headerDate(r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		dateTime = state.now
+		r.Header.Set("X-Amz-Date", dateTime.Format(ISO8601BasicFormat))
+	}
+
+	headers := signedHeaders(r)
+	hash, err := payloadHash(r, payload)
+	if err != nil {
+		return err
+	}
+
+	cr := canonicalRequest(r, query, headers, hash, state.options)
+	credential := s.credentialScope(dateTime.Format(ISO8601BasicFormatShort))
+	sts := stringToSign(asymmetricAlgorithm, dateTime, credential, cr)
+
+	// The signature is the hex encoded ASN.1 DER ECDSA signature of the hash
+	// of the string to sign.
+	//
+	digest := sha256.Sum256(sts)
+	sig, err := ecdsa.SignASN1(rand.Reader, state.key, digest[:])
+	if err != nil {
+		return err
+	}
+
+	authz := asymmetricAlgorithm + " Credential=" + state.accessID + "/" + credential +
+		", SignedHeaders=" + strings.Join(headers, ";") +
+		", Signature=" + string(toHex(sig))
+	r.Header.Set("Authorization", authz)
+
+	if debug != nil {
+		debug.CanonicalRequest = string(cr)
+		debug.StringToSign = string(sts)
+		debug.SignedHeaders = headers
+		debug.Authorization = authz
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package aws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeriveAsymmetricKey(t *testing.T) {
+	tests := []struct {
+		secret, access string
+		x, y           string
+	}{
+		// The public key of the SigV4a test suite.
+		{"wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE",
+			"b6618f6a65740a99e650b33b6b4b5bd0d43b176d721a3edfea7e7d2d56d936b1",
+			"865ed22a7eadc9c5cb9d2cbaca1b3699139fedc5043dc6661864218330c8e518"},
+		{"q+jcrXGc+0zWN6uzclKVhvMmUsIfRPa4rlRandom", "AKISORANDOMAASORANDOM",
+			"15d242ceebf8d8169fd6a8b5a746c41140414c3b07579038da06af89190fffcb",
+			"0515242cedd82e94799482e4c0514b505afccf2c0c98d6a553bf539f424c5ec0"},
+	}
+	for _, test := range tests {
+		key, err := DeriveAsymmetricKey(test.secret, test.access)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := fmt.Sprintf("%064x", key.X), fmt.Sprintf("%064x", key.Y)
+		if x != test.x || y != test.y {
+			t.Errorf("%s: public key\n%s\n%s\nwant\n%s\n%s", test.access, x, y, test.x, test.y)
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			t.Error(test.access, "public key is not on the curve")
+		}
+	}
+}
+
+// The get-vanilla case of the SigV4a test suite. ECDSA signatures are
+// randomized so the signature is checked with the suite's public key.
+func TestAsymmetricSignature(t *testing.T) {
+	date := time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)
+	signature, err := NewAsymmetricSignature("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE",
+		[]string{"us-east-1"}, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature.Clock = &testClock{now: date}
+
+	request, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug, err := signature.SignWithDebug(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonical := "GET\n/\n\n" +
+		"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\nx-amz-region-set:us-east-1\n\n" +
+		"host;x-amz-date;x-amz-region-set\n" + string(emptyHash)
+	if debug.CanonicalRequest != canonical {
+		t.Errorf("canonical request:\n%s\nwant:\n%s", debug.CanonicalRequest, canonical)
+	}
+	hashed := sha256.Sum256([]byte(canonical))
+	sts := "AWS4-ECDSA-P256-SHA256\n20150830T123600Z\n20150830/service/aws4_request\n" + hex.EncodeToString(hashed[:])
+	if debug.StringToSign != sts {
+		t.Errorf("string to sign:\n%s\nwant:\n%s", debug.StringToSign, sts)
+	}
+
+	authz := request.Header.Get("Authorization")
+	prefix := "AWS4-ECDSA-P256-SHA256 Credential=AKIDEXAMPLE/20150830/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date;x-amz-region-set, Signature="
+	if !strings.HasPrefix(authz, prefix) {
+		t.Fatal("unexpected authorization", authz)
+	}
+	sig, err := hex.DecodeString(authz[len(prefix):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, _ := new(big.Int).SetString("b6618f6a65740a99e650b33b6b4b5bd0d43b176d721a3edfea7e7d2d56d936b1", 16)
+	y, _ := new(big.Int).SetString("865ed22a7eadc9c5cb9d2cbaca1b3699139fedc5043dc6661864218330c8e518", 16)
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	digest := sha256.Sum256([]byte(sts))
+	if !ecdsa.VerifyASN1(public, digest[:], sig) {
+		t.Error("signature doesn't verify with the test suite's public key")
+	}
+}
+
+func TestAsymmetricSignatureRegionSet(t *testing.T) {
+	p := &expiringProvider{expiration: time.Now().Add(time.Hour)}
+	signature, err := NewAsymmetricSignatureFromProvider(p, []string{"us-east-1", "us-west-2"}, "s3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func() *http.Request {
+		request, err := http.NewRequest("GET", "https://mfzwi23gnjvgw.mrap.accesspoint.s3-global.amazonaws.com/key", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = signature.Sign(request, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return request
+	}
+
+	request := sign()
+	if got := request.Header.Get("X-Amz-Region-Set"); got != "us-east-1,us-west-2" {
+		t.Error("X-Amz-Region-Set is", got)
+	}
+	if got := request.Header.Get("X-Amz-Security-Token"); got != "token1" {
+		t.Error("X-Amz-Security-Token is", got)
+	}
+	if !strings.Contains(request.Header.Get("Authorization"), "x-amz-region-set;x-amz-security-token,") {
+		t.Error("unexpected authorization", request.Header.Get("Authorization"))
+	}
+
+	// Credentials about to expire are replaced along with the key.
+	key := signature.PrivateKey
+	signature.Expiration = time.Now()
+	request = sign()
+	if got := request.Header.Get("X-Amz-Security-Token"); got != "token2" || p.calls != 2 {
+		t.Error("credentials not refreshed", got, p.calls)
+	}
+	if signature.PrivateKey == key {
+		t.Error("key not derived again")
+	}
+
+	err = signature.Sign(request, ChunkedPayload(strings.NewReader(""), 0, 0))
+	if err == nil {
+		t.Error("expected error for chunked payload")
+	}
+}
@@ -0,0 +1,70 @@
+package sts
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// AssumeRoleProvider provides credentials by assuming a role.
+type AssumeRoleProvider struct {
+	Client          *Client
+	RoleARN         string
+	RoleSessionName string
+	ExternalID      string
+	Duration        time.Duration
+}
+
+// Credentials assumes the role and returns its temporary credentials.
+func (p *AssumeRoleProvider) Credentials() (*aws.Credentials, error) {
+	return p.Client.AssumeRole(p.RoleARN, p.RoleSessionName, p.ExternalID, p.Duration)
+}
+
+// NewAssumeRoleProvider returns a provider that assumes the role using the
+// client and caches the credentials until they are about to expire.
+func NewAssumeRoleProvider(c *Client, roleARN, sessionName string, duration time.Duration) *aws.CachedProvider {
+	return aws.NewCachedProvider(&AssumeRoleProvider{
+		Client:          c,
+		RoleARN:         roleARN,
+		RoleSessionName: sessionName,
+		Duration:        duration,
+	})
+}
+
+// SessionTokenProvider provides temporary credentials for the client's
+// signature.
+type SessionTokenProvider struct {
+	Client       *Client
+	SerialNumber string
+	TokenCode    string
+	Duration     time.Duration
+}
+
+// Credentials gets a session token and returns its temporary credentials.
+func (p *SessionTokenProvider) Credentials() (*aws.Credentials, error) {
+	return p.Client.GetSessionToken(p.SerialNumber, p.TokenCode, p.Duration)
+}
+
+// WebIdentityProvider provides credentials by assuming a role with a web
+// identity token read from a file, as supplied to pods by Kubernetes service
+// account token projection. The file is read on every call since such tokens
+// are rotated.
+type WebIdentityProvider struct {
+	Client          *Client
+	RoleARN         string
+	RoleSessionName string
+	TokenFile       string
+	Duration        time.Duration
+}
+
+// Credentials reads the token and assumes the role with it.
+func (p *WebIdentityProvider) Credentials() (*aws.Credentials, error) {
+	token, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return p.Client.AssumeRoleWithWebIdentity(p.RoleARN, p.RoleSessionName,
+		strings.TrimSpace(string(token)), p.Duration)
+}
@@ -0,0 +1,199 @@
+// Package sts is a client library for the AWS Security Token Service, which
+// issues temporary credentials.
+package sts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rdwilliamson/aws"
+)
+
+// DefaultEndpoint is the global STS endpoint, which is signed for us-east-1.
+const DefaultEndpoint = "https://sts.amazonaws.com"
+
+// A Client specifies the means and parameters of accessing STS.
+type Client struct {
+	// Client optionally specifies the HTTP client to use. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Endpoint optionally specifies the URL of the service. If empty,
+	// DefaultEndpoint is used.
+	Endpoint string
+
+	// Signature signs requests with the long term credentials used to request
+	// temporary ones. AssumeRoleWithWebIdentity requests are not signed and
+	// don't require it.
+	Signature *aws.Signature
+}
+
+// NewClient returns a Client with an initialized signature based on the
+// provided access credentials for the global endpoint.
+func NewClient(secret, access string) *Client {
+	return &Client{
+		Signature: aws.NewSignature(secret, access, aws.USEast1, "sts"),
+	}
+}
+
+// NewClientFromProvider returns a Client with a signature initialized from the
+// provider's credentials for the global endpoint.
+func NewClientFromProvider(p aws.CredentialsProvider) (*Client, error) {
+	signature, err := aws.NewSignatureFromProvider(p, aws.USEast1, "sts")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Signature: signature}, nil
+}
+
+func (c *Client) client() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+func (c *Client) endpoint() string {
+	if c.Endpoint == "" {
+		return DefaultEndpoint
+	}
+	return c.Endpoint
+}
+
+// credentials is the Credentials element common to the responses.
+type credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+// do performs the action with the parameters, signing the request if sign is
+// set, and returns the credentials from the response's result element.
+func (c *Client) do(action string, parameters url.Values, sign bool) (*aws.Credentials, error) {
+	// Build request.
+	parameters.Set("Action", action)
+	parameters.Set("Version", "2011-06-15")
+	body := []byte(parameters.Encode())
+
+	request, err := http.NewRequest("POST", c.endpoint()+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	if sign {
+		err = c.Signature.Sign(request, aws.MemoryPayload(body))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	request.ContentLength = int64(len(body))
+
+	// Perform request.
+	response, err := c.client().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, aws.ParseError(response)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse success response. Each action wraps the credentials in a result
+	// element named after it.
+	var result struct {
+		Credentials credentials
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("sts: response has no " + action + "Result")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == action+"Result" {
+			err = decoder.DecodeElement(&result, &start)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	rc := result.Credentials
+
+	expiration, err := time.Parse(time.RFC3339, rc.Expiration)
+	if err != nil {
+		return nil, err
+	}
+	return &aws.Credentials{
+		AccessID:     rc.AccessKeyId,
+		Secret:       rc.SecretAccessKey,
+		SessionToken: rc.SessionToken,
+		Expiration:   expiration,
+	}, nil
+}
+
+// durationSeconds adds the duration, if set, to the parameters in seconds.
+func durationSeconds(parameters url.Values, duration time.Duration) {
+	if duration > 0 {
+		parameters.Set("DurationSeconds", strconv.FormatInt(int64(duration/time.Second), 10))
+	}
+}
+
+// AssumeRole returns temporary credentials for the role. The session name
+// identifies the session in the role's account, external ID is required by
+// some third party roles, and duration defaults to one hour if zero.
+func (c *Client) AssumeRole(roleARN, sessionName, externalID string, duration time.Duration) (*aws.Credentials, error) {
+	parameters := url.Values{}
+	parameters.Set("RoleArn", roleARN)
+	parameters.Set("RoleSessionName", sessionName)
+	if externalID != "" {
+		parameters.Set("ExternalId", externalID)
+	}
+	durationSeconds(parameters, duration)
+	return c.do("AssumeRole", parameters, true)
+}
+
+// GetSessionToken returns temporary credentials for the signature's
+// credentials. If the user is required to use MFA the serial number of their
+// device and its current code must be supplied. The duration defaults to 12
+// hours if zero.
+func (c *Client) GetSessionToken(serialNumber, tokenCode string, duration time.Duration) (*aws.Credentials, error) {
+	parameters := url.Values{}
+	if serialNumber != "" {
+		parameters.Set("SerialNumber", serialNumber)
+		parameters.Set("TokenCode", tokenCode)
+	}
+	durationSeconds(parameters, duration)
+	return c.do("GetSessionToken", parameters, true)
+}
+
+// AssumeRoleWithWebIdentity returns temporary credentials for the role using
+// an OAuth 2.0 or OpenID Connect token from an identity provider. The request
+// is not signed. The duration defaults to one hour if zero.
+func (c *Client) AssumeRoleWithWebIdentity(roleARN, sessionName, token string, duration time.Duration) (*aws.Credentials, error) {
+	parameters := url.Values{}
+	parameters.Set("RoleArn", roleARN)
+	parameters.Set("RoleSessionName", sessionName)
+	parameters.Set("WebIdentityToken", token)
+	durationSeconds(parameters, duration)
+	return c.do("AssumeRoleWithWebIdentity", parameters, false)
+}
@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that signs every request with Signature
+// before sending it with Base, so a plain http.Client can make requests to AWS.
+// The request is signed for the time it is sent, which lets a request be
+// retried after its signature would have expired.
+//
+// Payload returns the payload each request is signed with, see Sign. If it is
+// nil, or returns nil, the request's body is read into memory to hash it
+// unless the request declares its payload's hash in the X-Amz-Content-Sha256
+// header or the signature's options say the payload is unsigned.
+//
+// An error signing a request is returned by RoundTrip, and so by the client,
+// rather than sending the request unsigned.
+type Transport struct {
+	Signature *Signature
+	Payload   func(r *http.Request) (Payload, error)
+
+	// Base optionally specifies the transport requests are sent with. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// RoundTrip signs a copy of the request and sends it. The request itself is
+// not modified, as required of a RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	signed, err := t.sign(r)
+	if err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base().RoundTrip(signed)
+}
+
+// sign returns a signed copy of the request.
+func (t *Transport) sign(r *http.Request) (*http.Request, error) {
+	if t.Signature == nil {
+		return nil, errors.New("aws: transport has no signature")
+	}
+
+	signed := r.Clone(r.Context())
+	var payload Payload
+	if t.Payload != nil {
+		var err error
+		payload, err = t.Payload(signed)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := t.Signature.Sign(signed, payload)
+	if err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,106 @@
+package aws
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Verify(r, testLookup); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, err.Error())
+			return
+		}
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	signature := NewSignature("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE", USEast1, "service")
+	transport := &Transport{Signature: signature}
+	client := &http.Client{Transport: transport}
+
+	do := func(request *http.Request) string {
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusOK {
+			t.Fatal(response.Status, string(body))
+		}
+		return string(body)
+	}
+
+	// The body is read into memory to sign it.
+	request, err := http.NewRequest("POST", server.URL+"/path?a=b", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := do(request); body != "body" {
+		t.Error("unexpected body", body)
+	}
+	if _, ok := request.Header["Authorization"]; ok {
+		t.Error("request was modified")
+	}
+
+	// Or signed with the payload strategy.
+	transport.Payload = func(r *http.Request) (Payload, error) {
+		return ReadSeekerPayload(strings.NewReader("seeker")), nil
+	}
+	request, err = http.NewRequest("PUT", server.URL+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := do(request); body != "seeker" {
+		t.Error("unexpected body", body)
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	sent := false
+	transport := &Transport{
+		Signature: NewSignature("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "AKIDEXAMPLE", USEast1, "service"),
+		Base: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			sent = true
+			return nil, nil
+		}),
+	}
+	client := &http.Client{Transport: transport}
+
+	request, err := http.NewRequest("GET", "https://host.foo.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("X-Amz-Date", time.Now().Format(time.RFC1123))
+	_, err = client.Do(request)
+	if e, ok := err.(*url.Error); !ok {
+		t.Error("expected *url.Error, got", err)
+	} else if _, ok := e.Err.(*time.ParseError); !ok {
+		t.Error("expected *time.ParseError, got", e.Err)
+	}
+	if sent {
+		t.Error("request sent without a signature")
+	}
+
+	transport.Signature = nil
+	if _, err := client.Get("https://host.foo.com/"); err == nil || sent {
+		t.Error("expected error without a signature")
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
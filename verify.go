@@ -0,0 +1,224 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far the time of a signed request may be from the clock
+// of the server verifying it.
+const MaxClockSkew = 15 * time.Minute
+
+// The errors returned by Verify. They use the codes and messages AWS responds
+// with so a server can send them back to the client as is.
+var (
+	// ErrIncompleteSignature is returned if the request isn't signed or the
+	// authentication information is malformed.
+	ErrIncompleteSignature = &Error{Code: "IncompleteSignature", Type: "Client",
+		Message: "The request signature does not conform to AWS standards."}
+
+	// ErrSignatureDoesNotMatch is returned if the signature isn't the one
+	// calculated for the request.
+	ErrSignatureDoesNotMatch = &Error{Code: "SignatureDoesNotMatch", Type: "Client",
+		Message: "The request signature we calculated does not match the signature you provided. " +
+			"Check your AWS Secret Access Key and signing method. Consult the service documentation for details."}
+
+	// ErrRequestExpired is returned if a presigned URL has expired.
+	ErrRequestExpired = &Error{Code: "RequestExpired", Type: "Client",
+		Message: "Request has expired."}
+
+	// ErrRequestTimeTooSkewed is returned if the time of the request is more
+	// than MaxClockSkew from the server's clock.
+	ErrRequestTimeTooSkewed = &Error{Code: "RequestTimeTooSkewed", Type: "Client",
+		Message: "The difference between the request time and the current time is too large."}
+
+	// ErrUnknownAccessKey should be returned by Verify's lookup function for
+	// access keys it doesn't know.
+	ErrUnknownAccessKey = &Error{Code: "UnrecognizedClientException", Type: "Client",
+		Message: "The security token included in the request is invalid."}
+)
+
+// authorization is the authentication information of a signed request.
+type authorization struct {
+	accessID   string
+	credential string // credential scope
+	date       string // date of the credential scope
+	region     string
+	service    string
+	headers    []string
+	signature  []byte
+	dateTime   time.Time
+	expires    time.Duration // zero unless presigned
+}
+
+// Verify checks that the HTTP request was signed with signature version 4 by
+// the holder of a secret key, either with an Authorization header as Sign
+// does or with a presigned URL as Presign does. Lookup is called with the
+// request's access key and returns its secret, or ErrUnknownAccessKey if the
+// key is unknown. It is used by servers, such as test stand-ins and proxies,
+// that accept requests made for AWS.
+//
+// The canonical request is rebuilt in the same way as when signing with the
+// default SigningOptions, which reads the request's body into memory to hash
+// it unless it is a chunked payload, whose chunk signatures are not verified,
+// or an unsigned payload. The body is replaced so
+// it can still be read. The time of the request must be within MaxClockSkew
+// of now and a presigned URL must not have expired.
+//
+// Returns nil if the signature is valid, one of ErrIncompleteSignature,
+// ErrSignatureDoesNotMatch, ErrRequestExpired, or ErrRequestTimeTooSkewed,
+// lookup's error, or an error reading the body.
+func Verify(r *http.Request, lookup func(accessID string) (secret string, err error)) error {
+	return verify(r, lookup, time.Now())
+}
+
+// verify verifies the request at time now, which lets the test suite verify
+// requests signed long ago.
+func verify(r *http.Request, lookup func(accessID string) (string, error), now time.Time) error {
+	query, err := parseQuery(r.URL.RawQuery)
+	if err != nil {
+		return ErrIncompleteSignature
+	}
+
+	var a *authorization
+	if query.Get("X-Amz-Algorithm") != "" {
+		a, err = parsePresignedQuery(query)
+	} else {
+		a, err = parseAuthorization(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.dateTime.Format(ISO8601BasicFormatShort) != a.date {
+		return ErrSignatureDoesNotMatch
+	}
+	if a.expires != 0 && now.After(a.dateTime.Add(a.expires)) {
+		return ErrRequestExpired
+	}
+	if skew := now.Sub(a.dateTime); (skew > MaxClockSkew && a.expires == 0) || skew < -MaxClockSkew {
+		return ErrRequestTimeTooSkewed
+	}
+
+	secret, err := lookup(a.accessID)
+	if err != nil {
+		return err
+	}
+
+	// Presign signs an empty payload, or UNSIGNED-PAYLOAD if its options say
+	// so, a chunked payload is only signed as being streamed, and an unsigned
+	// payload isn't signed.
+	//
+	hash := emptyHash
+	if a.expires == 0 {
+		switch declared := r.Header.Get("X-Amz-Content-Sha256"); declared {
+		case streamingPayloadHash, unsignedPayload:
+			hash = []byte(declared)
+		default:
+			var mem []byte
+			if r.Body != nil {
+				mem, err = ioutil.ReadAll(r.Body)
+				if err != nil {
+					return err
+				}
+				err = r.Body.Close()
+				if err != nil {
+					return err
+				}
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(mem))
+			sum := sha256.Sum256(mem)
+			hash = toHex(sum[:])
+		}
+	}
+
+	key := deriveSigningKey(secret, a.date, a.region, a.service)
+	matches := func(hash []byte) bool {
+		cr := canonicalRequest(r, query, a.headers, hash, SigningOptions{})
+		sts := stringToSign(algorithm, a.dateTime, a.credential, cr)
+		return hmac.Equal(signature(key, sts), a.signature)
+	}
+	if !matches(hash) && (a.expires == 0 || !matches([]byte(unsignedPayload))) {
+		return ErrSignatureDoesNotMatch
+	}
+	return nil
+}
+
+// parseAuthorization parses the Authorization header and date of a request
+// signed by Sign.
+func parseAuthorization(r *http.Request) (*authorization, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, algorithm+" ") {
+		return nil, ErrIncompleteSignature
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(header[len(algorithm)+1:], ",") {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return nil, ErrIncompleteSignature
+		}
+		fields[strings.TrimSpace(field[:i])] = strings.TrimSpace(field[i+1:])
+	}
+
+	a, err := parseCredential(fields["Credential"], fields["SignedHeaders"], fields["Signature"])
+	if err != nil {
+		return nil, err
+	}
+
+	var ok bool
+	a.dateTime, ok, err = headerDate(r)
+	if !ok || err != nil {
+		return nil, ErrIncompleteSignature
+	}
+	return a, nil
+}
+
+// parsePresignedQuery parses and removes the signature from the query string
+// of a URL created by Presign, leaving the parameters that were signed.
+func parsePresignedQuery(query url.Values) (*authorization, error) {
+	if query.Get("X-Amz-Algorithm") != algorithm {
+		return nil, ErrIncompleteSignature
+	}
+	a, err := parseCredential(query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"),
+		query.Get("X-Amz-Signature"))
+	if err != nil {
+		return nil, err
+	}
+	query.Del("X-Amz-Signature")
+
+	a.dateTime, err = time.Parse(ISO8601BasicFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, ErrIncompleteSignature
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 1 || time.Duration(expires)*time.Second > MaxPresignExpires {
+		return nil, ErrIncompleteSignature
+	}
+	a.expires = time.Duration(expires) * time.Second
+	return a, nil
+}
+
+// parseCredential parses the credential, signed headers, and signature
+// common to both ways of signing.
+func parseCredential(credential, headers, signature string) (*authorization, error) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" || headers == "" || signature == "" {
+		return nil, ErrIncompleteSignature
+	}
+	return &authorization{
+		accessID:   parts[0],
+		credential: strings.Join(parts[1:], "/"),
+		date:       parts[1],
+		region:     parts[2],
+		service:    parts[3],
+		headers:    strings.Split(headers, ";"),
+		signature:  []byte(signature),
+	}, nil
+}
@@ -0,0 +1,203 @@
+package aws
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSuiteTime = time.Date(2011, time.September, 9, 23, 36, 0, 0, time.UTC)
+
+func testLookup(accessID string) (string, error) {
+	if accessID != "AKIDEXAMPLE" {
+		return "", ErrUnknownAccessKey
+	}
+	return "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", nil
+}
+
+// readTestRequest reads a request from the test suites as a server would
+// receive it.
+func readTestRequest(t *testing.T, name string) *http.Request {
+	raw, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _, err := readSuiteRequest(raw, strings.Contains(name, "2015"))
+	if err != nil {
+		t.Fatal(name, err)
+	}
+	for _, values := range request.Header {
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+	}
+	return request
+}
+
+func TestVerifySuite(t *testing.T) {
+	files, err := getAWSSuiteFiles("aws4_testsuite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		request := readTestRequest(t, filepath.Join("aws4_testsuite", f+".sreq"))
+		if err := verify(request, testLookup, testSuiteTime); err != nil {
+			t.Error(f, err)
+		}
+	}
+
+	dir := "aws4_testsuite_2015"
+	now := time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)
+	err = filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil || filepath.Ext(name) != ".req" {
+			return err
+		}
+		request := readTestRequest(t, name)
+		authz, err := ioutil.ReadFile(strings.TrimSuffix(name, ".req") + ".authz")
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", string(authz))
+		if err := verify(request, testLookup, now); err != nil {
+			t.Error(name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyPresignSuite(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("aws4_presign_testsuite", "*.req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		base := f[:len(f)-len(".req")]
+		request := readTestRequest(t, f)
+		purl, err := ioutil.ReadFile(base + ".purl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(string(bytes.TrimSpace(purl)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.URL.RawQuery = u.RawQuery
+
+		if err := verify(request, testLookup, testSuiteTime); err != nil {
+			t.Error(base, err)
+		}
+		if err := verify(request, testLookup, testSuiteTime.Add(25*time.Hour)); err != ErrRequestExpired {
+			t.Error(base, "expected ErrRequestExpired, got", err)
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	signed := func() *http.Request {
+		return readTestRequest(t, filepath.Join("aws4_testsuite", "post-x-www-form-urlencoded.sreq"))
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *http.Request)
+		now    time.Time
+		err    error
+	}{
+		{"unsigned", func(r *http.Request) { r.Header.Del("Authorization") }, testSuiteTime, ErrIncompleteSignature},
+		{"malformed", func(r *http.Request) {
+			r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909")
+		}, testSuiteTime, ErrIncompleteSignature},
+		{"unknown key", func(r *http.Request) {
+			r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "AKIDEXAMPLE", "AKIDOTHER", 1))
+		}, testSuiteTime, ErrUnknownAccessKey},
+		{"header", func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }, testSuiteTime, ErrSignatureDoesNotMatch},
+		{"body", func(r *http.Request) { r.Body = ioutil.NopCloser(strings.NewReader("foo=baz")) }, testSuiteTime, ErrSignatureDoesNotMatch},
+		{"path", func(r *http.Request) { r.URL.Path = "/other" }, testSuiteTime, ErrSignatureDoesNotMatch},
+		{"late", func(r *http.Request) {}, testSuiteTime.Add(MaxClockSkew + time.Second), ErrRequestTimeTooSkewed},
+		{"early", func(r *http.Request) {}, testSuiteTime.Add(-MaxClockSkew - time.Second), ErrRequestTimeTooSkewed},
+		{"within skew", func(r *http.Request) {}, testSuiteTime.Add(MaxClockSkew), nil},
+	}
+	for _, test := range tests {
+		request := signed()
+		test.modify(request)
+		if err := verify(request, testLookup, test.now); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// The body can still be read after verifying.
+	request := signed()
+	if err := verify(request, testLookup, testSuiteTime); err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil || string(body) != "foo=bar" {
+		t.Errorf("body %q, %v", body, err)
+	}
+}
+
+func TestVerifySigned(t *testing.T) {
+	secret, access := "secret", "access"
+	lookup := func(accessID string) (string, error) {
+		if accessID != access {
+			return "", ErrUnknownAccessKey
+		}
+		return secret, nil
+	}
+	signature := NewSessionSignature(secret, access, "token", EU1, "glacier")
+
+	request, err := http.NewRequest("PUT", "https://glacier.eu-west-1.amazonaws.com/-/vaults/examplevault",
+		strings.NewReader("archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("X-Amz-Glacier-Version", "2012-06-01")
+	err = signature.Sign(request, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(request, lookup); err != nil {
+		t.Error("signed request:", err)
+	}
+
+	request, err = http.NewRequest("GET", "https://glacier.eu-west-1.amazonaws.com/-/vaults", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := signature.Presign(request, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.URL = u
+	if err := Verify(request, lookup); err != nil {
+		t.Error("presigned request:", err)
+	}
+
+	signature.Options.UnsignedPayload = true
+	request.URL, err = url.Parse("https://glacier.eu-west-1.amazonaws.com/-/vaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = signature.Presign(request, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.URL = u
+	if err := Verify(request, lookup); err != nil {
+		t.Error("unsigned payload presigned request:", err)
+	}
+
+	secret = "other"
+	if err := Verify(request, lookup); err != ErrSignatureDoesNotMatch {
+		t.Error("expected ErrSignatureDoesNotMatch, got", err)
+	}
+}